@@ -8,4 +8,16 @@ require (
 	gopkg.in/telebot.v3 v3.2.1
 )
 
-require github.com/streadway/amqp v1.1.0 // indirect
+require (
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/mochi-mqtt/server/v2 v2.6.6
+	github.com/streadway/amqp v1.1.0
+)
+
+require (
+	github.com/gorilla/websocket v1.5.0 // indirect
+	github.com/rs/xid v1.4.0 // indirect
+	golang.org/x/net v0.23.0 // indirect
+	golang.org/x/sync v0.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,229 @@
+package api
+
+import (
+    "bytes"
+    "crypto/subtle"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "telegramassist/internal/application"
+    "telegramassist/internal/domain"
+)
+
+const (
+    maxBatchAlerts = 1000
+    maxBatchBytes  = 5 << 20
+)
+
+var errSerialNotAllowed = errors.New("the gateway may not report alerts for this device")
+
+// GatewayTokens maps the bearer token of each gateway to the serials it may
+// report alerts for.
+type GatewayTokens map[string][]string
+
+// ParseGatewayTokens reads the gateway credentials written as
+// "token1=SERIAL1,SERIAL2;token2=SERIAL3". An empty spec has no gateways.
+func ParseGatewayTokens(spec string) (GatewayTokens, error) {
+    tokens := make(GatewayTokens)
+    for _, entry := range strings.Split(spec, ";") {
+        if entry = strings.TrimSpace(entry); entry == "" {
+            continue
+        }
+        token, serials, ok := strings.Cut(entry, "=")
+        token = strings.TrimSpace(token)
+        if !ok || token == "" {
+            return nil, fmt.Errorf("invalid gateway credential %q: want token=SERIAL,...", entry)
+        }
+        if _, seen := tokens[token]; seen {
+            return nil, errors.New("duplicate gateway token")
+        }
+        tokens[token] = []string{}
+        for _, serial := range strings.Split(serials, ",") {
+            if serial = strings.TrimSpace(serial); serial != "" {
+                tokens[token] = append(tokens[token], serial)
+            }
+        }
+    }
+    return tokens, nil
+}
+
+// authenticate checks the bearer token of the request and returns the
+// serials its gateway may report alerts for, with the status code to answer
+// when it fails.
+func (t GatewayTokens) authenticate(r *http.Request) (map[string]bool, int, error) {
+    if len(t) == 0 {
+        return nil, http.StatusServiceUnavailable, errAPIDisabled
+    }
+    header := r.Header.Get("Authorization")
+    if !strings.HasPrefix(header, "Bearer ") {
+        return nil, http.StatusUnauthorized, errMissingToken
+    }
+    given := []byte(strings.TrimPrefix(header, "Bearer "))
+
+    var serials []string
+    found := false
+    for token, allowed := range t {
+        if subtle.ConstantTimeCompare(given, []byte(token)) == 1 {
+            serials, found = allowed, true
+        }
+    }
+    if !found {
+        return nil, http.StatusForbidden, errInvalidToken
+    }
+    allowed := make(map[string]bool, len(serials))
+    for _, serial := range serials {
+        allowed[serial] = true
+    }
+    return allowed, 0, nil
+}
+
+type batchItemResponse struct {
+    Index       int                `json:"index"`
+    Status      string             `json:"status"` // accepted, duplicate or rejected
+    AlertID     int                `json:"alert_id,omitempty"`
+    AlertStatus domain.AlertStatus `json:"alert_status,omitempty"`
+    Error       string             `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+    Accepted   int                 `json:"accepted"`
+    Duplicates int                 `json:"duplicates"`
+    Rejected   int                 `json:"rejected"`
+    Results    []batchItemResponse `json:"results"`
+}
+
+// HandleAlertBatch accepts the alerts buffered by a gateway, as a JSON array
+// or as NDJSON (one alert per line):
+//
+//   POST /api/alerts/batch
+//
+// The gateway authenticates with its bearer token (see GatewayTokens) and
+// the alerts of devices it may not report for are rejected. Every item is
+// validated on its own and gets a result at its index. The answer is 202
+// when any alert was accepted and 200 otherwise.
+func (h *AlertHandler) HandleAlertBatch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+        return
+    }
+
+    allowed, status, err := h.gateways.authenticate(r)
+    if err != nil {
+        writeError(w, status, err)
+        return
+    }
+
+    body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+    if err != nil {
+        writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("error reading request body: %v", err))
+        return
+    }
+    alerts, errs, err := parseAlertBatch(body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    // Only the items that decoded, of devices the gateway may report for,
+    // are submitted; valid maps them back.
+    var valid []*domain.Alert
+    var indexes []int
+    for i, alert := range alerts {
+        if errs[i] == nil && !allowed[alert.NumeroSerie] {
+            errs[i] = errSerialNotAllowed
+        }
+        if errs[i] == nil {
+            valid = append(valid, alert)
+            indexes = append(indexes, i)
+        }
+    }
+
+    submitted, err := h.alertQueue.SubmitBatch(valid)
+    if errors.Is(err, application.ErrAlertQueueClosed) {
+        writeError(w, http.StatusServiceUnavailable, err)
+        return
+    }
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    response := batchResponse{Results: make([]batchItemResponse, len(alerts))}
+    for i, err := range errs {
+        if err != nil {
+            response.Results[i] = batchItemResponse{Index: i, Status: "rejected", Error: err.Error()}
+        }
+    }
+    for j, result := range submitted {
+        item := batchItemResponse{Index: indexes[j]}
+        switch {
+        case result.Err != nil:
+            item.Status = "rejected"
+            item.Error = result.Err.Error()
+        case result.Created:
+            item.Status = "accepted"
+        default:
+            item.Status = "duplicate"
+        }
+        if result.Record != nil {
+            item.AlertID = result.Record.ID
+            item.AlertStatus = result.Record.Status
+        }
+        response.Results[indexes[j]] = item
+    }
+    for _, item := range response.Results {
+        switch item.Status {
+        case "accepted":
+            response.Accepted++
+        case "duplicate":
+            response.Duplicates++
+        default:
+            response.Rejected++
+        }
+    }
+
+    status = http.StatusOK
+    if response.Accepted > 0 {
+        status = http.StatusAccepted
+    }
+    writeJSON(w, status, response)
+}
+
+// parseAlertBatch decodes a JSON array or NDJSON. An item that does not
+// decode gets its error at its index in the second slice; the error returned
+// is about the batch as a whole.
+func parseAlertBatch(body []byte) ([]*domain.Alert, []error, error) {
+    var items []json.RawMessage
+    trimmed := bytes.TrimSpace(body)
+    if bytes.HasPrefix(trimmed, []byte("[")) {
+        if err := json.Unmarshal(trimmed, &items); err != nil {
+            return nil, nil, fmt.Errorf("error decoding alert batch: %v", err)
+        }
+    } else {
+        for _, line := range bytes.Split(trimmed, []byte("\n")) {
+            if line = bytes.TrimSpace(line); len(line) > 0 {
+                items = append(items, line)
+            }
+        }
+    }
+
+    switch {
+    case len(items) == 0:
+        return nil, nil, errors.New("the batch has no alerts")
+    case len(items) > maxBatchAlerts:
+        return nil, nil, fmt.Errorf("a batch can hold at most %d alerts", maxBatchAlerts)
+    }
+
+    alerts := make([]*domain.Alert, len(items))
+    errs := make([]error, len(items))
+    for i, item := range items {
+        alerts[i] = &domain.Alert{}
+        if err := json.Unmarshal(item, alerts[i]); err != nil {
+            errs[i] = fmt.Errorf("error decoding alert: %v", err)
+        }
+    }
+    return alerts, errs, nil
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "telegramassist/internal/application"
+    "testing"
+)
+
+func TestParseAlertBatch(t *testing.T) {
+    tests := []struct {
+        name    string
+        body    string
+        serials []string // of the items that decode
+        bad     []int    // indexes of the items that do not
+        wantErr string
+    }{
+        {
+            name:    "JSON array",
+            body:    `[{"numeroSerie":"ESP32-001","sensor":"KY_026","estado":1},{"numeroSerie":"ESP32-002","sensor":"MQ_2","estado":0}]`,
+            serials: []string{"ESP32-001", "ESP32-002"},
+        },
+        {
+            name:    "NDJSON with blank lines",
+            body:    "{\"numeroSerie\":\"ESP32-001\",\"sensor\":\"KY_026\",\"estado\":1}\n\n  {\"numeroSerie\":\"ESP32-002\",\"sensor\":\"KY_026\",\"estado\":0}\n",
+            serials: []string{"ESP32-001", "ESP32-002"},
+        },
+        {
+            name:    "bad item is rejected at its index",
+            body:    "{\"numeroSerie\":\"ESP32-001\",\"sensor\":\"KY_026\",\"estado\":1}\nnot json\n{\"numeroSerie\":\"ESP32-003\",\"sensor\":\"KY_026\",\"estado\":1}",
+            serials: []string{"ESP32-001", "", "ESP32-003"},
+            bad:     []int{1},
+        },
+        {
+            name:    "item of the wrong type in an array",
+            body:    `[{"numeroSerie":"ESP32-001","sensor":"KY_026","estado":"uno"}]`,
+            serials: []string{"ESP32-001"},
+            bad:     []int{0},
+        },
+        {
+            name:    "malformed array",
+            body:    `[{"numeroSerie":"ESP32-001"`,
+            wantErr: "error decoding alert batch",
+        },
+        {
+            name:    "empty body",
+            body:    "  \n",
+            wantErr: "the batch has no alerts",
+        },
+        {
+            name:    "empty array",
+            body:    "[]",
+            wantErr: "the batch has no alerts",
+        },
+        {
+            name:    "too many alerts",
+            body:    strings.Repeat("{}\n", maxBatchAlerts+1),
+            wantErr: "at most",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            alerts, errs, err := parseAlertBatch([]byte(tt.body))
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("error = %v, want %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("error = %v", err)
+            }
+            if len(alerts) != len(tt.serials) || len(errs) != len(tt.serials) {
+                t.Fatalf("got %d alerts and %d errors, want %d", len(alerts), len(errs), len(tt.serials))
+            }
+
+            var bad []int
+            for i, err := range errs {
+                if err != nil {
+                    bad = append(bad, i)
+                }
+            }
+            if !reflect.DeepEqual(bad, tt.bad) {
+                t.Errorf("items with errors = %v, want %v", bad, tt.bad)
+            }
+            for i, serial := range tt.serials {
+                if errs[i] == nil && alerts[i].NumeroSerie != serial {
+                    t.Errorf("alert %d NumeroSerie = %q, want %q", i, alerts[i].NumeroSerie, serial)
+                }
+            }
+        })
+    }
+}
+
+func TestParseGatewayTokens(t *testing.T) {
+    tests := []struct {
+        name    string
+        spec    string
+        want    GatewayTokens
+        wantErr bool
+    }{
+        {name: "empty", spec: "", want: GatewayTokens{}},
+        {
+            name: "several gateways",
+            spec: " tok1 = ESP32-001, ESP32-002 ;tok2=ESP32-003;",
+            want: GatewayTokens{"tok1": {"ESP32-001", "ESP32-002"}, "tok2": {"ESP32-003"}},
+        },
+        {name: "gateway with no serials", spec: "tok1=", want: GatewayTokens{"tok1": {}}},
+        {name: "missing serials", spec: "tok1", wantErr: true},
+        {name: "missing token", spec: "=ESP32-001", wantErr: true},
+        {name: "duplicate token", spec: "tok1=ESP32-001;tok1=ESP32-002", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := ParseGatewayTokens(tt.spec)
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatalf("got %v, want an error", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("error = %v", err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("got %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestHandleAlertBatchChecksGatewayToken(t *testing.T) {
+    // Requests that pass authentication reach the queue, which these
+    // handlers do not have.
+    tests := []struct {
+        name     string
+        gateways GatewayTokens
+        header   string
+        status   int
+    }{
+        {name: "no gateways configured", header: "Bearer tok1", status: http.StatusServiceUnavailable},
+        {name: "missing token", gateways: GatewayTokens{"tok1": {"ESP32-001"}}, status: http.StatusUnauthorized},
+        {name: "unknown token", gateways: GatewayTokens{"tok1": {"ESP32-001"}}, header: "Bearer tok2", status: http.StatusForbidden},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            h := NewAlertHandler(nil, nil, tt.gateways)
+            r := httptest.NewRequest(http.MethodPost, "/api/alerts/batch",
+                strings.NewReader(`[{"numeroSerie":"ESP32-001","sensor":"KY_026","estado":1}]`))
+            if tt.header != "" {
+                r.Header.Set("Authorization", tt.header)
+            }
+            w := httptest.NewRecorder()
+
+            h.HandleAlertBatch(w, r)
+            if w.Code != tt.status {
+                t.Errorf("status = %d, want %d", w.Code, tt.status)
+            }
+        })
+    }
+}
+
+func TestHandleAlertBatchRejectsSerialsOfOtherGateways(t *testing.T) {
+    // No alert reaches the store, so the queue needs neither.
+    h := NewAlertHandler(application.NewAlertQueue(nil, nil, 1, 0), nil, GatewayTokens{"tok1": {"ESP32-001"}})
+    r := httptest.NewRequest(http.MethodPost, "/api/alerts/batch",
+        strings.NewReader("{\"numeroSerie\":\"ESP32-002\",\"sensor\":\"KY_026\",\"estado\":1}\nnot json"))
+    r.Header.Set("Authorization", "Bearer tok1")
+    w := httptest.NewRecorder()
+
+    h.HandleAlertBatch(w, r)
+    if w.Code != http.StatusOK {
+        t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+    }
+    var response batchResponse
+    if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+        t.Fatal(err)
+    }
+    if response.Rejected != 2 || len(response.Results) != 2 {
+        t.Fatalf("response = %+v, want 2 rejected items", response)
+    }
+    if got := response.Results[0].Error; got != errSerialNotAllowed.Error() {
+        t.Errorf("item 0 error = %q, want %q", got, errSerialNotAllowed)
+    }
+}
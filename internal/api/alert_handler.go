@@ -3,40 +3,141 @@ package api
 import (
     "bytes"
     "encoding/json"
+    "errors"
     "fmt"
     "io"
     "net/http"
+    "strconv"
+    "strings"
     "telegramassist/internal/application"
     "telegramassist/internal/domain"
-    "telegramassist/internal/infrastructure/rabbitmq"
+    "time"
 )
 
-type UserNotification struct {
-    UserID        int    `json:"user_id"`
-    Username      string `json:"username"`
-    Email         string `json:"email"`
-    SensorType    string `json:"sensor_type"`
-    Estado        int    `json:"estado"`
-    Activacion    string `json:"activacion"`
-    Desactivacion string `json:"desactivacion"`
-    NumeroSerie   string `json:"numero_serie"`
+// Headers an ESP32 must send to sign an alert request
+const (
+    HeaderDeviceSerial = "X-Device-Serial"
+    HeaderKeyID        = "X-Key-Id"
+    HeaderTimestamp    = "X-Signature-Timestamp"
+    HeaderNonce        = "X-Signature-Nonce"
+    HeaderSignature    = "X-Signature"
+)
+
+const alertsPrefix = "/api/alerts/"
+
+type alertStatusResponse struct {
+    ID            int                    `json:"alert_id"`
+    EventID       string                 `json:"event_id,omitempty"`
+    Status        domain.AlertStatus     `json:"status"`
+    NumeroSerie   string                 `json:"numero_serie"`
+    Sensor        string                 `json:"sensor"`
+    Attempts      int                    `json:"attempts"`
+    ReceivedAt    time.Time              `json:"received_at"`
+    ProcessedAt   *time.Time             `json:"processed_at,omitempty"`
+    IncidentID    int                    `json:"incident_id,omitempty"`
+    Error         string                 `json:"error,omitempty"`
+    Notifications []domain.ChannelReport `json:"notifications,omitempty"`
+    Duplicate     bool                   `json:"duplicate,omitempty"`
+}
+
+func newAlertStatusResponse(record *domain.AlertRecord) alertStatusResponse {
+    response := alertStatusResponse{
+        ID:          record.ID,
+        EventID:     record.Alert.EventID,
+        Status:      record.Status,
+        NumeroSerie: record.Alert.NumeroSerie,
+        Sensor:      record.Alert.Sensor,
+        Attempts:    record.Attempts,
+        ReceivedAt:  record.ReceivedAt,
+        ProcessedAt: record.ProcessedAt,
+        IncidentID:  record.IncidentID,
+        Error:       record.LastError,
+    }
+    if record.Delivery != nil {
+        response.Notifications = record.Delivery.Channels
+    }
+    return response
 }
 
+// AlertHandler accepts signed alerts from the devices and answers with 202
+// as soon as they are stored; they are processed by the AlertQueue. Retries
+// of an alert already stored get 200 with the status of the original.
+//
+//   POST /api/alerts
+//   GET  /api/alerts/{id}
+//
+// and, from gateways, POST /api/alerts/batch (see HandleAlertBatch).
 type AlertHandler struct {
-    esp32Service        *application.ESP32Service
-    notificationService *application.NotificationService
-    rabbitMQService     *rabbitmq.RabbitMQService
+    alertQueue        *application.AlertQueue
+    deviceAuthService *application.DeviceAuthService
+    gateways          GatewayTokens
 }
 
 func NewAlertHandler(
-    esp32Service *application.ESP32Service,
-    notificationService *application.NotificationService,
-    rabbitMQService *rabbitmq.RabbitMQService,
+    alertQueue *application.AlertQueue,
+    deviceAuthService *application.DeviceAuthService,
+    gateways GatewayTokens,
 ) *AlertHandler {
     return &AlertHandler{
-        esp32Service:        esp32Service,
-        notificationService: notificationService,
-        rabbitMQService:     rabbitMQService,
+        alertQueue:        alertQueue,
+        deviceAuthService: deviceAuthService,
+        gateways:          gateways,
+    }
+}
+
+// authenticate verifies the HMAC signature of the request body and returns
+// the serial of the device that signed it.
+func (h *AlertHandler) authenticate(r *http.Request) (string, error) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        return "", fmt.Errorf("error reading request body: %v", err)
+    }
+
+    r.Body = io.NopCloser(bytes.NewBuffer(body))
+
+    sig := application.DeviceSignature{
+        Serial:    r.Header.Get(HeaderDeviceSerial),
+        KeyID:     r.Header.Get(HeaderKeyID),
+        Timestamp: r.Header.Get(HeaderTimestamp),
+        Nonce:     r.Header.Get(HeaderNonce),
+        Signature: r.Header.Get(HeaderSignature),
+    }
+    if err := h.deviceAuthService.Verify(sig, body); err != nil {
+        return "", err
+    }
+    return sig.Serial, nil
+}
+
+func authStatus(err error) int {
+    switch {
+    case errors.Is(err, application.ErrUnknownDevice),
+        errors.Is(err, application.ErrDeviceNotProvisioned),
+        errors.Is(err, application.ErrCredentialInactive),
+        errors.Is(err, application.ErrSerialMismatch):
+        return http.StatusForbidden
+    case errors.Is(err, application.ErrMissingSignature),
+        errors.Is(err, application.ErrInvalidTimestamp),
+        errors.Is(err, application.ErrTimestampOutOfRange),
+        errors.Is(err, application.ErrInvalidSignature),
+        errors.Is(err, application.ErrReplayedNonce):
+        return http.StatusUnauthorized
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+// submitStatus maps the errors of AlertQueue.Submit to a status code; an
+// unknown device is answered as in authStatus.
+func submitStatus(err error) int {
+    switch {
+    case errors.Is(err, domain.ErrInvalidAlert):
+        return http.StatusBadRequest
+    case errors.Is(err, application.ErrUnknownDevice):
+        return authStatus(err)
+    case errors.Is(err, application.ErrAlertQueueClosed):
+        return http.StatusServiceUnavailable
+    default:
+        return http.StatusInternalServerError
     }
 }
 
@@ -56,26 +157,12 @@ func (h *AlertHandler) parseAlert(r *http.Request) (*domain.Alert, error) {
     return &alert, nil
 }
 
-func (h *AlertHandler) createUserNotification(user *domain.User, alert *domain.Alert) UserNotification {
-    return UserNotification{
-        UserID:        user.ID,
-        Username:      user.Username,
-        Email:         user.Email,
-        SensorType:    alert.Sensor,
-        Estado:        alert.Estado,
-        Activacion:    alert.FechaActivacion,
-        Desactivacion: alert.FechaDesactivacion,
-        NumeroSerie:   alert.NumeroSerie,
-    }
-}
-
-func (h *AlertHandler) sendSuccessResponse(w http.ResponseWriter, chatCount int) {
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]string{
-        "status":        "success",
-        "message":       "Alerta procesada correctamente",
-        "chats_notified": fmt.Sprintf("%d", chatCount),
+func (h *AlertHandler) sendAcceptedResponse(w http.ResponseWriter, record *domain.AlertRecord) {
+    writeJSON(w, http.StatusAccepted, map[string]interface{}{
+        "status":     "accepted",
+        "message":    "Alerta recibida",
+        "alert_id":   record.ID,
+        "status_url": fmt.Sprintf("%s%d", alertsPrefix, record.ID),
     })
 }
 
@@ -85,44 +172,62 @@ func (h *AlertHandler) HandleAlert(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    serial, err := h.authenticate(r)
+    if err != nil {
+        writeError(w, authStatus(err), err)
+        return
+    }
+
     alert, err := h.parseAlert(r)
     if err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
 
-    chatIDs, err := h.processAlert(alert)
+    if alert.NumeroSerie != serial {
+        writeError(w, http.StatusForbidden, application.ErrSerialMismatch)
+        return
+    }
+
+    record, created, err := h.alertQueue.Submit(alert)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, submitStatus(err), err)
+        return
+    }
+
+    if !created {
+        // A retry of an alert already received: answer with what became of
+        // the original instead of processing it again.
+        response := newAlertStatusResponse(record)
+        response.Duplicate = true
+        writeJSON(w, http.StatusOK, response)
         return
     }
 
-    h.sendSuccessResponse(w, len(chatIDs))
+    h.sendAcceptedResponse(w, record)
 }
 
-func (h *AlertHandler) processAlert(alert *domain.Alert) ([]int64, error) {
-    user, err := h.esp32Service.GetUserByESP32Serial(alert.NumeroSerie)
-    if err != nil {
-        return nil, fmt.Errorf("error getting user: %v", err)
+func (h *AlertHandler) HandleAlertStatus(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+        return
     }
 
-    if user != nil {
-        notification := h.createUserNotification(user, alert)
-        if err := h.rabbitMQService.PublishNotification(notification); err != nil {
-            fmt.Printf("Error sending to RabbitMQ: %v\n", err)
-        }
+    id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, alertsPrefix), "/"))
+    if err != nil || id <= 0 {
+        http.NotFound(w, r)
+        return
     }
 
-    chatIDs, err := h.esp32Service.ProcessAlert(alert)
-    if err != nil {
-        return nil, fmt.Errorf("error processing alert: %v", err)
+    record, err := h.alertQueue.Get(id)
+    if errors.Is(err, application.ErrAlertNotFound) {
+        writeError(w, http.StatusNotFound, err)
+        return
     }
-
-    for _, chatID := range chatIDs {
-        if err := h.notificationService.SendTelegramNotification(chatID, alert); err != nil {
-            fmt.Printf("Error sending telegram notification to %d: %v\n", chatID, err)
-        }
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
     }
 
-    return chatIDs, nil
-}
\ No newline at end of file
+    writeJSON(w, http.StatusOK, newAlertStatusResponse(record))
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "telegramassist/internal/application"
+    "telegramassist/internal/domain"
+    "time"
+)
+
+const deviceKeysPrefix = "/api/admin/devices/"
+
+type credentialResponse struct {
+    KeyID     string     `json:"key_id"`
+    Secret    string     `json:"secret,omitempty"`
+    Status    string     `json:"status"`
+    CreatedAt time.Time  `json:"created_at"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+    RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type rotateRequest struct {
+    OverlapSeconds int `json:"overlap_seconds"`
+}
+
+// DeviceKeyHandler exposes the admin API used to manage ESP32 credentials:
+//
+//   GET    /api/admin/devices/{serial}/keys          list credentials
+//   POST   /api/admin/devices/{serial}/keys          issue a new credential
+//   POST   /api/admin/devices/{serial}/keys/rotate   rotate with overlap
+//   DELETE /api/admin/devices/{serial}/keys/{keyID}  revoke a credential
+type DeviceKeyHandler struct {
+    deviceKeyService *application.DeviceKeyService
+}
+
+func NewDeviceKeyHandler(deviceKeyService *application.DeviceKeyService) *DeviceKeyHandler {
+    return &DeviceKeyHandler{deviceKeyService: deviceKeyService}
+}
+
+func (h *DeviceKeyHandler) HandleDeviceKeys(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, deviceKeysPrefix), "/"), "/")
+    if len(parts) < 2 || parts[0] == "" || parts[1] != "keys" || len(parts) > 3 {
+        http.NotFound(w, r)
+        return
+    }
+    serial := parts[0]
+
+    switch {
+    case len(parts) == 2 && r.Method == http.MethodGet:
+        h.list(w, serial)
+    case len(parts) == 2 && r.Method == http.MethodPost:
+        h.issue(w, serial)
+    case len(parts) == 3 && parts[2] == "rotate" && r.Method == http.MethodPost:
+        h.rotate(w, r, serial)
+    case len(parts) == 3 && r.Method == http.MethodDelete:
+        h.revoke(w, serial, parts[2])
+    default:
+        http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+    }
+}
+
+func (h *DeviceKeyHandler) list(w http.ResponseWriter, serial string) {
+    credentials, err := h.deviceKeyService.List(serial)
+    if err != nil {
+        writeError(w, keyErrorStatus(err), err)
+        return
+    }
+
+    now := time.Now()
+    response := make([]credentialResponse, 0, len(credentials))
+    for _, credential := range credentials {
+        response = append(response, newCredentialResponse(credential, now, false))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "numero_serie": serial,
+        "keys":         response,
+    })
+}
+
+func (h *DeviceKeyHandler) issue(w http.ResponseWriter, serial string) {
+    credential, err := h.deviceKeyService.Issue(serial)
+    if err != nil {
+        writeError(w, keyErrorStatus(err), err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, newCredentialResponse(*credential, time.Now(), true))
+}
+
+func (h *DeviceKeyHandler) rotate(w http.ResponseWriter, r *http.Request, serial string) {
+    var req rotateRequest
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeError(w, http.StatusBadRequest, fmt.Errorf("error decoding rotate request: %v", err))
+            return
+        }
+    }
+
+    credential, overlapEnds, err := h.deviceKeyService.Rotate(serial, time.Duration(req.OverlapSeconds)*time.Second)
+    if err != nil {
+        writeError(w, keyErrorStatus(err), err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, map[string]interface{}{
+        "key":                  newCredentialResponse(*credential, time.Now(), true),
+        "previous_keys_expire": overlapEnds.UTC(),
+    })
+}
+
+func (h *DeviceKeyHandler) revoke(w http.ResponseWriter, serial, keyID string) {
+    if err := h.deviceKeyService.Revoke(serial, keyID); err != nil {
+        writeError(w, keyErrorStatus(err), err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func newCredentialResponse(credential domain.DeviceCredential, now time.Time, withSecret bool) credentialResponse {
+    response := credentialResponse{
+        KeyID:     credential.KeyID,
+        Status:    credential.Status(now),
+        CreatedAt: credential.CreatedAt,
+        ExpiresAt: credential.ExpiresAt,
+        RevokedAt: credential.RevokedAt,
+    }
+    // The secret is only shown once, when the credential is created.
+    if withSecret {
+        response.Secret = credential.Secret
+    }
+    return response
+}
+
+func keyErrorStatus(err error) int {
+    switch {
+    case errors.Is(err, application.ErrUnknownDevice),
+        errors.Is(err, application.ErrCredentialNotFound):
+        return http.StatusNotFound
+    default:
+        return http.StatusInternalServerError
+    }
+}
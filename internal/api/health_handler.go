@@ -0,0 +1,40 @@
+package api
+
+import "net/http"
+
+// HealthCheck reports the state of a dependency and whether it is usable.
+type HealthCheck interface {
+    Health() (string, bool)
+}
+
+type componentHealth struct {
+    State   string `json:"state"`
+    Healthy bool   `json:"healthy"`
+}
+
+// HealthHandler serves GET /health. It answers 503 when any dependency is
+// unhealthy so load balancers and monitors can act on it.
+type HealthHandler struct {
+    checks map[string]HealthCheck
+}
+
+func NewHealthHandler(checks map[string]HealthCheck) *HealthHandler {
+    return &HealthHandler{checks: checks}
+}
+
+func (h *HealthHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+    status, code := "ok", http.StatusOK
+    components := make(map[string]componentHealth, len(h.checks))
+    for name, check := range h.checks {
+        state, healthy := check.Health()
+        components[name] = componentHealth{State: state, Healthy: healthy}
+        if !healthy {
+            status, code = "degraded", http.StatusServiceUnavailable
+        }
+    }
+
+    writeJSON(w, code, map[string]interface{}{
+        "status":     status,
+        "components": components,
+    })
+}
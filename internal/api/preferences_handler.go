@@ -0,0 +1,84 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "telegramassist/internal/application"
+    "telegramassist/internal/domain"
+)
+
+const usersPrefix = "/api/admin/users/"
+
+type preferencesBody struct {
+    DeviceChats bool `json:"device_chats"`
+    Email       bool `json:"email"`
+    SMS         bool `json:"sms"`
+}
+
+// PreferencesHandler exposes the notification preferences of users:
+//
+//   GET /api/admin/users/{id}/preferences
+//   PUT /api/admin/users/{id}/preferences
+type PreferencesHandler struct {
+    preferencesService *application.PreferencesService
+}
+
+func NewPreferencesHandler(preferencesService *application.PreferencesService) *PreferencesHandler {
+    return &PreferencesHandler{preferencesService: preferencesService}
+}
+
+func (h *PreferencesHandler) HandlePreferences(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, usersPrefix), "/"), "/")
+    if len(parts) != 2 || parts[1] != "preferences" {
+        http.NotFound(w, r)
+        return
+    }
+    userID, err := strconv.Atoi(parts[0])
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+
+    switch r.Method {
+    case http.MethodGet:
+        preferences, err := h.preferencesService.Get(userID)
+        if err != nil {
+            writeError(w, preferencesErrorStatus(err), err)
+            return
+        }
+        writeJSON(w, http.StatusOK, newPreferencesBody(preferences))
+    case http.MethodPut:
+        var body preferencesBody
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+            writeError(w, http.StatusBadRequest, fmt.Errorf("error decoding preferences: %v", err))
+            return
+        }
+        preferences := domain.NotificationPreferences{
+            EnableDeviceChats: body.DeviceChats,
+            EnableEmail:       body.Email,
+            EnableSMS:         body.SMS,
+        }
+        if err := h.preferencesService.Save(userID, preferences); err != nil {
+            writeError(w, preferencesErrorStatus(err), err)
+            return
+        }
+        writeJSON(w, http.StatusOK, body)
+    default:
+        http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+    }
+}
+
+func newPreferencesBody(p domain.NotificationPreferences) preferencesBody {
+    return preferencesBody{DeviceChats: p.EnableDeviceChats, Email: p.EnableEmail, SMS: p.EnableSMS}
+}
+
+func preferencesErrorStatus(err error) int {
+    if errors.Is(err, application.ErrUserNotFound) {
+        return http.StatusNotFound
+    }
+    return http.StatusInternalServerError
+}
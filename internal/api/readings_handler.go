@@ -0,0 +1,209 @@
+package api
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "telegramassist/internal/application"
+    "telegramassist/internal/domain"
+    "time"
+)
+
+const (
+    devicesPrefix = "/api/devices/"
+
+    defaultReadingsLimit = 50
+    maxReadingsLimit     = 500
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type readingResponse struct {
+    ID              int    `json:"id"`
+    FechaActivacion string `json:"fecha_activacion"`
+    Estado          string `json:"estado"`
+}
+
+type readingsResponse struct {
+    NumeroSerie string            `json:"numero_serie"`
+    Readings    []readingResponse `json:"readings"`
+    Total       int               `json:"total"`
+    Activations int               `json:"activations"`
+    NextCursor  string            `json:"next_cursor,omitempty"`
+}
+
+// ReadingsHandler serves the read-only API used by the dashboard:
+//
+//   GET /api/devices/{serial}/readings?from=&to=&sort=asc|desc&limit=&cursor=
+//   GET /api/devices/{serial}/readings/latest
+//
+// from and to accept RFC 3339, "2006-01-02 15:04:05" or "2006-01-02".
+type ReadingsHandler struct {
+    esp32Service *application.ESP32Service
+    ky026Service *application.KY026Service
+}
+
+func NewReadingsHandler(esp32Service *application.ESP32Service, ky026Service *application.KY026Service) *ReadingsHandler {
+    return &ReadingsHandler{
+        esp32Service: esp32Service,
+        ky026Service: ky026Service,
+    }
+}
+
+func (h *ReadingsHandler) HandleDeviceReadings(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+        return
+    }
+
+    parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, devicesPrefix), "/"), "/")
+    if len(parts) < 2 || parts[0] == "" || parts[1] != "readings" || len(parts) > 3 ||
+        (len(parts) == 3 && parts[2] != "latest") {
+        http.NotFound(w, r)
+        return
+    }
+    serial := parts[0]
+
+    device, err := h.esp32Service.GetDevice(serial)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+    if device == nil {
+        writeError(w, http.StatusNotFound, application.ErrUnknownDevice)
+        return
+    }
+
+    if len(parts) == 3 {
+        h.latest(w, serial)
+        return
+    }
+    h.list(w, r, serial)
+}
+
+func (h *ReadingsHandler) latest(w http.ResponseWriter, serial string) {
+    reading, err := h.ky026Service.GetLastReading(serial)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+    if reading == nil {
+        writeError(w, http.StatusNotFound, errors.New("no readings for this device"))
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "numero_serie": serial,
+        "reading":      newReadingResponse(*reading),
+    })
+}
+
+func (h *ReadingsHandler) list(w http.ResponseWriter, r *http.Request, serial string) {
+    query, err := parseReadingQuery(r, serial)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err)
+        return
+    }
+
+    // Ask for one more reading than requested to know if there is a next page.
+    limit := query.Limit
+    query.Limit++
+    page, err := h.ky026Service.ListReadings(query)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err)
+        return
+    }
+
+    response := readingsResponse{
+        NumeroSerie: serial,
+        Readings:    make([]readingResponse, 0, limit),
+        Total:       page.Total,
+        Activations: page.Activations,
+    }
+    readings := page.Readings
+    if len(readings) > limit {
+        readings = readings[:limit]
+        response.NextCursor = encodeCursor(readings[limit-1].ID)
+    }
+    for _, reading := range readings {
+        response.Readings = append(response.Readings, newReadingResponse(reading))
+    }
+    writeJSON(w, http.StatusOK, response)
+}
+
+func parseReadingQuery(r *http.Request, serial string) (domain.ReadingQuery, error) {
+    values := r.URL.Query()
+    query := domain.ReadingQuery{Serial: serial, Limit: defaultReadingsLimit}
+
+    var err error
+    if v := values.Get("from"); v != "" {
+        if query.From, err = parseTimeParam(v); err != nil {
+            return query, fmt.Errorf("invalid from: %v", err)
+        }
+    }
+    if v := values.Get("to"); v != "" {
+        if query.To, err = parseTimeParam(v); err != nil {
+            return query, fmt.Errorf("invalid to: %v", err)
+        }
+    }
+
+    switch values.Get("sort") {
+    case "", "desc":
+    case "asc":
+        query.Ascending = true
+    default:
+        return query, errors.New("sort must be asc or desc")
+    }
+
+    if v := values.Get("limit"); v != "" {
+        limit, err := strconv.Atoi(v)
+        if err != nil || limit <= 0 || limit > maxReadingsLimit {
+            return query, fmt.Errorf("limit must be between 1 and %d", maxReadingsLimit)
+        }
+        query.Limit = limit
+    }
+
+    if v := values.Get("cursor"); v != "" {
+        if query.Cursor, err = decodeCursor(v); err != nil {
+            return query, err
+        }
+    }
+    return query, nil
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, v); err == nil {
+        return t, nil
+    }
+    if t, err := time.ParseInLocation(domain.FechaLayout, v, time.Local); err == nil {
+        return t, nil
+    }
+    return time.ParseInLocation("2006-01-02", v, time.Local)
+}
+
+// Cursors are opaque to clients; they wrap the ID of the last reading returned.
+func encodeCursor(id int) string {
+    return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(cursor)
+    if err != nil {
+        return 0, errInvalidCursor
+    }
+    id, err := strconv.Atoi(string(raw))
+    if err != nil || id <= 0 {
+        return 0, errInvalidCursor
+    }
+    return id, nil
+}
+
+func newReadingResponse(reading domain.KY026Reading) readingResponse {
+    return readingResponse{
+        ID:              reading.ID,
+        FechaActivacion: reading.FechaActivacion,
+        Estado:          reading.Estado,
+    }
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strings"
+)
+
+var (
+    errMissingToken = errors.New("missing bearer token")
+    errInvalidToken = errors.New("invalid bearer token")
+    errAPIDisabled  = errors.New("endpoint disabled: no API token configured")
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+    writeJSON(w, status, map[string]string{
+        "status":  "error",
+        "message": err.Error(),
+    })
+}
+
+// RequireToken only lets through requests carrying "Authorization: Bearer
+// <token>". An empty token disables the endpoint.
+func RequireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+    return requireToken(token, false, next)
+}
+
+// RequireStreamToken is RequireToken for the event stream: since browsers
+// cannot set headers on an EventSource, the token is also accepted in the
+// access_token query parameter. It is not offered elsewhere so that tokens
+// do not end up in URLs and logs.
+func RequireStreamToken(token string, next http.HandlerFunc) http.HandlerFunc {
+    return requireToken(token, true, next)
+}
+
+func requireToken(token string, allowQuery bool, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if token == "" {
+            writeError(w, http.StatusServiceUnavailable, errAPIDisabled)
+            return
+        }
+
+        var given string
+        if allowQuery {
+            given = r.URL.Query().Get("access_token")
+        }
+        if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+            given = strings.TrimPrefix(header, "Bearer ")
+        }
+        if given == "" {
+            writeError(w, http.StatusUnauthorized, errMissingToken)
+            return
+        }
+        if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+            writeError(w, http.StatusForbidden, errInvalidToken)
+            return
+        }
+
+        next(w, r)
+    }
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "telegramassist/internal/application"
+    "time"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+// StreamHandler pushes processed alerts to clients using Server-Sent Events:
+//
+//   GET /api/stream?serial={serial}
+//
+// Clients reconnecting with a Last-Event-ID header receive the buffered
+// events they missed.
+type StreamHandler struct {
+    alertHub *application.AlertHub
+}
+
+func NewStreamHandler(alertHub *application.AlertHub) *StreamHandler {
+    return &StreamHandler{alertHub: alertHub}
+}
+
+func (h *StreamHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+        return
+    }
+
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming not supported", http.StatusInternalServerError)
+        return
+    }
+
+    lastEventID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+    sub, backlog := h.alertHub.Subscribe(r.URL.Query().Get("serial"), lastEventID)
+    defer h.alertHub.Unsubscribe(sub)
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(http.StatusOK)
+    fmt.Fprint(w, "retry: 3000\n\n")
+
+    for _, event := range backlog {
+        if err := writeEvent(w, event); err != nil {
+            return
+        }
+    }
+    flusher.Flush()
+
+    heartbeat := time.NewTicker(streamHeartbeat)
+    defer heartbeat.Stop()
+
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+        case event, ok := <-sub.C:
+            if !ok {
+                return
+            }
+            if err := writeEvent(w, event); err != nil {
+                return
+            }
+            flusher.Flush()
+        case <-heartbeat.C:
+            if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+                return
+            }
+            flusher.Flush()
+        }
+    }
+}
+
+func writeEvent(w http.ResponseWriter, event application.AlertEvent) error {
+    data, err := json.Marshal(event)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintf(w, "id: %d\nevent: alert\ndata: %s\n\n", event.ID, data)
+    return err
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "telegramassist/internal/application"
+    "telegramassist/internal/domain"
+    "time"
+)
+
+const webhooksPrefix = "/api/admin/webhooks"
+
+type webhookRequest struct {
+    NumeroSerie string `json:"numero_serie"`
+    UserID      int    `json:"user_id"`
+    URL         string `json:"url"`
+    Format      string `json:"format"`
+}
+
+type webhookResponse struct {
+    ID                  int        `json:"id"`
+    NumeroSerie         string     `json:"numero_serie,omitempty"`
+    UserID              int        `json:"user_id,omitempty"`
+    URL                 string     `json:"url"`
+    Format              string     `json:"format"`
+    Secret              string     `json:"secret,omitempty"`
+    Active              bool       `json:"active"`
+    ConsecutiveFailures int        `json:"consecutive_failures"`
+    DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+    CreatedAt           time.Time  `json:"created_at"`
+}
+
+type webhookDeliveryResponse struct {
+    ID             int        `json:"id"`
+    Status         string     `json:"status"`
+    Attempts       int        `json:"attempts"`
+    LastStatusCode int        `json:"last_status_code,omitempty"`
+    LastError      string     `json:"last_error,omitempty"`
+    NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+    CreatedAt      time.Time  `json:"created_at"`
+    DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookHandler exposes the admin API of webhook subscriptions:
+//
+//   GET    /api/admin/webhooks                   list subscriptions
+//   POST   /api/admin/webhooks                   subscribe an endpoint
+//   DELETE /api/admin/webhooks/{id}              delete a subscription
+//   POST   /api/admin/webhooks/{id}/enable       enable (resets failures)
+//   POST   /api/admin/webhooks/{id}/disable      disable
+//   GET    /api/admin/webhooks/{id}/deliveries   latest deliveries
+type WebhookHandler struct {
+    webhookNotifier *application.WebhookNotifier
+}
+
+func NewWebhookHandler(webhookNotifier *application.WebhookNotifier) *WebhookHandler {
+    return &WebhookHandler{webhookNotifier: webhookNotifier}
+}
+
+func (h *WebhookHandler) HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+    path := strings.Trim(strings.TrimPrefix(r.URL.Path, webhooksPrefix), "/")
+    if path == "" {
+        switch r.Method {
+        case http.MethodGet:
+            h.list(w)
+        case http.MethodPost:
+            h.subscribe(w, r)
+        default:
+            http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+        }
+        return
+    }
+
+    parts := strings.Split(path, "/")
+    id, err := strconv.Atoi(parts[0])
+    if err != nil || len(parts) > 2 {
+        http.NotFound(w, r)
+        return
+    }
+
+    switch {
+    case len(parts) == 1 && r.Method == http.MethodDelete:
+        h.delete(w, id)
+    case len(parts) == 2 && parts[1] == "enable" && r.Method == http.MethodPost:
+        h.setActive(w, id, true)
+    case len(parts) == 2 && parts[1] == "disable" && r.Method == http.MethodPost:
+        h.setActive(w, id, false)
+    case len(parts) == 2 && parts[1] == "deliveries" && r.Method == http.MethodGet:
+        h.deliveries(w, id)
+    default:
+        http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+    }
+}
+
+func (h *WebhookHandler) list(w http.ResponseWriter) {
+    subscriptions, err := h.webhookNotifier.List()
+    if err != nil {
+        writeError(w, webhookErrorStatus(err), err)
+        return
+    }
+
+    response := make([]webhookResponse, 0, len(subscriptions))
+    for _, subscription := range subscriptions {
+        response = append(response, newWebhookResponse(subscription, false))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": response})
+}
+
+func (h *WebhookHandler) subscribe(w http.ResponseWriter, r *http.Request) {
+    var req webhookRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, fmt.Errorf("error decoding webhook request: %v", err))
+        return
+    }
+
+    subscription, err := h.webhookNotifier.Subscribe(domain.WebhookSubscription{
+        ESP32Serial: req.NumeroSerie,
+        UserID:      req.UserID,
+        URL:         req.URL,
+        Format:      domain.WebhookFormat(req.Format),
+    })
+    if err != nil {
+        writeError(w, webhookErrorStatus(err), err)
+        return
+    }
+    writeJSON(w, http.StatusCreated, newWebhookResponse(*subscription, true))
+}
+
+func (h *WebhookHandler) delete(w http.ResponseWriter, id int) {
+    if err := h.webhookNotifier.Delete(id); err != nil {
+        writeError(w, webhookErrorStatus(err), err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) setActive(w http.ResponseWriter, id int, active bool) {
+    if err := h.webhookNotifier.SetActive(id, active); err != nil {
+        writeError(w, webhookErrorStatus(err), err)
+        return
+    }
+    subscription, err := h.webhookNotifier.Get(id)
+    if err != nil {
+        writeError(w, webhookErrorStatus(err), err)
+        return
+    }
+    writeJSON(w, http.StatusOK, newWebhookResponse(*subscription, false))
+}
+
+func (h *WebhookHandler) deliveries(w http.ResponseWriter, id int) {
+    deliveries, err := h.webhookNotifier.Deliveries(id)
+    if err != nil {
+        writeError(w, webhookErrorStatus(err), err)
+        return
+    }
+
+    response := make([]webhookDeliveryResponse, 0, len(deliveries))
+    for _, d := range deliveries {
+        item := webhookDeliveryResponse{
+            ID:             d.ID,
+            Status:         string(d.Status),
+            Attempts:       d.Attempts,
+            LastStatusCode: d.LastStatusCode,
+            LastError:      d.LastError,
+            CreatedAt:      d.CreatedAt,
+            DeliveredAt:    d.DeliveredAt,
+        }
+        if d.Status == domain.WebhookDeliveryPending {
+            next := d.NextAttemptAt
+            item.NextAttemptAt = &next
+        }
+        response = append(response, item)
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "webhook_id": id,
+        "deliveries": response,
+    })
+}
+
+func newWebhookResponse(subscription domain.WebhookSubscription, withSecret bool) webhookResponse {
+    response := webhookResponse{
+        ID:                  subscription.ID,
+        NumeroSerie:         subscription.ESP32Serial,
+        UserID:              subscription.UserID,
+        URL:                 subscription.URL,
+        Format:              string(subscription.Format),
+        Active:              subscription.Active,
+        ConsecutiveFailures: subscription.ConsecutiveFailures,
+        DisabledAt:          subscription.DisabledAt,
+        CreatedAt:           subscription.CreatedAt,
+    }
+    // Like device keys, the signing secret is only shown on creation.
+    if withSecret {
+        response.Secret = subscription.Secret
+    }
+    return response
+}
+
+func webhookErrorStatus(err error) int {
+    switch {
+    case errors.Is(err, application.ErrWebhookNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, application.ErrInvalidWebhook),
+        errors.Is(err, application.ErrInvalidWebhookURL),
+        errors.Is(err, application.ErrWebhookFormat):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
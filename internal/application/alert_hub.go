@@ -0,0 +1,119 @@
+package application
+
+import (
+    "sync"
+    "telegramassist/internal/domain"
+    "time"
+)
+
+// AlertEvent is an alert as broadcast to stream subscribers.
+type AlertEvent struct {
+    ID             uint64                `json:"id"`
+    Alert          domain.Alert          `json:"alert"`
+    IncidentID     int                   `json:"incident_id,omitempty"`
+    IncidentStatus domain.IncidentStatus `json:"incident_status,omitempty"`
+    ProcessedAt    time.Time             `json:"processed_at"`
+}
+
+// AlertSubscription receives the events of one subscriber. C is closed when
+// the subscriber is removed, either by Unsubscribe or because it fell behind.
+type AlertSubscription struct {
+    C      <-chan AlertEvent
+    c      chan AlertEvent
+    serial string
+}
+
+// AlertHub broadcasts processed alerts to in-process subscribers and keeps
+// the last events in a ring buffer so clients can resume after reconnecting.
+type AlertHub struct {
+    mu          sync.Mutex
+    nextID      uint64
+    buffer      []AlertEvent
+    start       int
+    size        int
+    subscribers map[*AlertSubscription]struct{}
+}
+
+// NewAlertHub creates a hub remembering the last bufferSize events. IDs start
+// at the current time so they keep growing across restarts.
+func NewAlertHub(bufferSize int) *AlertHub {
+    return &AlertHub{
+        nextID:      uint64(time.Now().UnixNano()),
+        buffer:      make([]AlertEvent, bufferSize),
+        subscribers: make(map[*AlertSubscription]struct{}),
+    }
+}
+
+func (h *AlertHub) Publish(alert *domain.Alert, incident *domain.Incident) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    h.nextID++
+    event := AlertEvent{ID: h.nextID, Alert: *alert, ProcessedAt: time.Now().UTC()}
+    if incident != nil {
+        event.IncidentID = incident.ID
+        event.IncidentStatus = incident.Status
+    }
+    h.remember(event)
+
+    for sub := range h.subscribers {
+        if sub.serial != "" && sub.serial != alert.NumeroSerie {
+            continue
+        }
+        select {
+        case sub.c <- event:
+        default:
+            // A subscriber that cannot keep up is dropped; it can resume
+            // with Last-Event-ID.
+            h.remove(sub)
+        }
+    }
+}
+
+// Subscribe registers a subscriber for serial (empty means every device) and
+// returns the buffered events newer than lastEventID to replay first.
+func (h *AlertHub) Subscribe(serial string, lastEventID uint64) (*AlertSubscription, []AlertEvent) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    c := make(chan AlertEvent, 64)
+    sub := &AlertSubscription{C: c, c: c, serial: serial}
+    h.subscribers[sub] = struct{}{}
+
+    var backlog []AlertEvent
+    if lastEventID > 0 {
+        for i := 0; i < h.size; i++ {
+            event := h.buffer[(h.start+i)%len(h.buffer)]
+            if event.ID > lastEventID && (serial == "" || event.Alert.NumeroSerie == serial) {
+                backlog = append(backlog, event)
+            }
+        }
+    }
+    return sub, backlog
+}
+
+func (h *AlertHub) Unsubscribe(sub *AlertSubscription) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.remove(sub)
+}
+
+func (h *AlertHub) remove(sub *AlertSubscription) {
+    if _, ok := h.subscribers[sub]; ok {
+        delete(h.subscribers, sub)
+        close(sub.c)
+    }
+}
+
+func (h *AlertHub) remember(event AlertEvent) {
+    if len(h.buffer) == 0 {
+        return
+    }
+    if h.size < len(h.buffer) {
+        h.buffer[(h.start+h.size)%len(h.buffer)] = event
+        h.size++
+        return
+    }
+    h.buffer[h.start] = event
+    h.start = (h.start + 1) % len(h.buffer)
+}
@@ -0,0 +1,187 @@
+package application
+
+import (
+    "fmt"
+    "log"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+)
+
+// AlertProcessor runs the pipeline shared by every ingestion transport
+// (HTTP, AMQP, MQTT): store the alert, stream it, notify it through every
+// channel and drive the incident escalation. The flap detector decides
+// which alerts of a toggling sensor are not notified until they settle.
+type AlertProcessor struct {
+    esp32Service        *ESP32Service
+    notificationManager ports.NotificationManager
+    escalationService   *EscalationService
+    alertHub            *AlertHub
+    flapDetector        *FlapDetector
+}
+
+func NewAlertProcessor(
+    esp32Service *ESP32Service,
+    notificationManager ports.NotificationManager,
+    escalationService *EscalationService,
+    alertHub *AlertHub,
+    flapDetector *FlapDetector,
+) *AlertProcessor {
+    return &AlertProcessor{
+        esp32Service:        esp32Service,
+        notificationManager: notificationManager,
+        escalationService:   escalationService,
+        alertHub:            alertHub,
+        flapDetector:        flapDetector,
+    }
+}
+
+// Validate checks the alert fields and that its device exists.
+func (p *AlertProcessor) Validate(alert *domain.Alert) error {
+    if err := alert.Validate(); err != nil {
+        return err
+    }
+    device, err := p.esp32Service.GetDevice(alert.NumeroSerie)
+    if err != nil {
+        return fmt.Errorf("error getting device: %v", err)
+    }
+    if device == nil {
+        return ErrUnknownDevice
+    }
+    return nil
+}
+
+// Process validates and processes a stored alert. Invalid alerts and alerts
+// of unknown devices fail with an error wrapping domain.ErrInvalidAlert or
+// ErrUnknownDevice. Once the alert is checkpointed, notification failures do
+// not fail it: they are reported in the Delivery of the result.
+func (p *AlertProcessor) Process(record *domain.AlertRecord) (*ProcessedAlert, error) {
+    if err := p.Validate(&record.Alert); err != nil {
+        return nil, err
+    }
+    return p.process(record, p.flapDetector.Observe(record))
+}
+
+// SaveBatch stores the alerts of a batch (see ESP32Service.SaveAlertBatch).
+func (p *AlertProcessor) SaveBatch(records []*domain.AlertRecord) ([]bool, error) {
+    return p.esp32Service.SaveAlertBatch(records)
+}
+
+// ProcessBatch processes stored alerts of one device that arrived together
+// with their readings, notifying them once. The flap detector observes them
+// in order, and the notification is about the last one it did not suppress.
+func (p *AlertProcessor) ProcessBatch(records []*domain.AlertRecord) (*ProcessedAlert, error) {
+    flaps := make([]domain.FlapState, len(records))
+    for i, record := range records {
+        flaps[i] = p.flapDetector.Observe(record)
+    }
+
+    result, err := p.esp32Service.ProcessAlertBatch(records, flaps)
+    if err != nil {
+        return nil, fmt.Errorf("error processing alert batch: %v", err)
+    }
+
+    for _, record := range records {
+        p.alertHub.Publish(&record.Alert, result.Incident)
+    }
+
+    alert := result.Alert
+    notification := domain.AlertNotification{
+        Alert:          alert,
+        Incident:       result.Incident,
+        IncidentOpened: result.IncidentOpened,
+        OutboxQueued:   result.OutboxQueued,
+        Coalesced:      len(records),
+    }
+    if !result.Notify() {
+        notification.Suppressed = result.Flap.SuppressReason()
+    }
+    result.Delivery, err = p.notificationManager.NotifyAlert(notification)
+    if err != nil {
+        log.Printf("Error looking up recipients of %s: %v", alert.NumeroSerie, err)
+    }
+
+    if result.IncidentOpened && !result.Incident.Status.Closed() {
+        if err := p.escalationService.Schedule(result.Incident); err != nil {
+            log.Printf("Error scheduling escalation of incident %d: %v", result.Incident.ID, err)
+        }
+    }
+
+    return result, nil
+}
+
+// Renotify sends the notification of an alert found at its checkpoint with
+// NotifyPending set: the process stopped before the alert was marked
+// processed, so the notification may not have gone out.
+func (p *AlertProcessor) Renotify(record *domain.AlertRecord) (*ProcessedAlert, error) {
+    result := &ProcessedAlert{
+        Alert:          &record.Alert,
+        IncidentOpened: record.IncidentOpened,
+        OutboxQueued:   record.OutboxQueued,
+    }
+    if record.IncidentID != 0 {
+        incident, err := p.esp32Service.GetIncident(record.IncidentID)
+        if err != nil {
+            return nil, fmt.Errorf("error getting incident %d: %v", record.IncidentID, err)
+        }
+        result.Incident = incident
+    }
+
+    var err error
+    result.Delivery, err = p.notificationManager.NotifyAlert(domain.AlertNotification{
+        Alert:          result.Alert,
+        Incident:       result.Incident,
+        IncidentOpened: result.IncidentOpened,
+        OutboxQueued:   result.OutboxQueued,
+    })
+    if err != nil {
+        log.Printf("Error looking up recipients of %s: %v", record.Alert.NumeroSerie, err)
+    }
+
+    if result.IncidentOpened && result.Incident != nil && !result.Incident.Status.Closed() {
+        if err := p.escalationService.Schedule(result.Incident); err != nil {
+            log.Printf("Error scheduling escalation of incident %d: %v", result.Incident.ID, err)
+        }
+    }
+    return result, nil
+}
+
+// Settle processes again an alert the flap detector suppressed, now that
+// its sensor is quiet, so its incident is closed and it is notified.
+func (p *AlertProcessor) Settle(record *domain.AlertRecord) (*ProcessedAlert, error) {
+    return p.process(record, domain.FlapState{Settled: true})
+}
+
+func (p *AlertProcessor) process(record *domain.AlertRecord, flap domain.FlapState) (*ProcessedAlert, error) {
+    alert := &record.Alert
+    result, err := p.esp32Service.ProcessAlert(record, flap)
+    if err != nil {
+        return nil, fmt.Errorf("error processing alert: %v", err)
+    }
+
+    // The stream already got a settled alert when it arrived.
+    if !flap.Settled {
+        p.alertHub.Publish(alert, result.Incident)
+    }
+
+    notification := domain.AlertNotification{
+        Alert:          alert,
+        Incident:       result.Incident,
+        IncidentOpened: result.IncidentOpened,
+        OutboxQueued:   result.OutboxQueued,
+    }
+    if !result.Notify() {
+        notification.Suppressed = flap.SuppressReason()
+    }
+    result.Delivery, err = p.notificationManager.NotifyAlert(notification)
+    if err != nil {
+        log.Printf("Error looking up recipients of %s: %v", alert.NumeroSerie, err)
+    }
+
+    if result.IncidentOpened {
+        if err := p.escalationService.Schedule(result.Incident); err != nil {
+            log.Printf("Error scheduling escalation of incident %d: %v", result.Incident.ID, err)
+        }
+    }
+
+    return result, nil
+}
@@ -0,0 +1,142 @@
+package application
+
+import (
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "testing"
+    "time"
+)
+
+type fakeESP32Repository struct {
+    domain.ESP32Repository
+}
+
+func (fakeESP32Repository) GetBySerial(serial string) (*domain.ESP32, error) {
+    return &domain.ESP32{Serial: serial}, nil
+}
+
+func (fakeESP32Repository) GetUserByESP32Serial(serial string) (*domain.User, error) {
+    return nil, nil
+}
+
+// fakeIncidentManager keeps the incidents of one sensor in memory.
+type fakeIncidentManager struct {
+    ports.IncidentManager
+    incidents []*domain.Incident
+}
+
+func (m *fakeIncidentManager) GetOpenIncident(serial string, sensor string) (*domain.Incident, error) {
+    for _, incident := range m.incidents {
+        if !incident.Status.Closed() {
+            copy := *incident
+            return &copy, nil
+        }
+    }
+    return nil, nil
+}
+
+func (m *fakeIncidentManager) CreateIncident(incident *domain.Incident, actor string) error {
+    incident.ID = len(m.incidents) + 1
+    copy := *incident
+    m.incidents = append(m.incidents, &copy)
+    return nil
+}
+
+func (m *fakeIncidentManager) SaveTransition(incident *domain.Incident, transition domain.IncidentTransition) error {
+    *m.incidents[incident.ID-1] = *incident
+    return nil
+}
+
+type fakeNotificationManager struct {
+    ports.NotificationManager
+    notifications []domain.AlertNotification
+}
+
+func (m *fakeNotificationManager) NotifyAlert(notification domain.AlertNotification) (domain.DeliveryReport, error) {
+    m.notifications = append(m.notifications, notification)
+    return domain.DeliveryReport{}, nil
+}
+
+type fakeEscalationManager struct {
+    ports.EscalationManager
+}
+
+func (fakeEscalationManager) GetEscalationPolicy(serial string) (*domain.EscalationPolicy, error) {
+    return nil, nil
+}
+
+func (m *fakeAlertManager) CheckpointAlerts(checkpoint domain.AlertCheckpoint) error {
+    m.checkpoints = append(m.checkpoints, checkpoint)
+    return nil
+}
+
+// newTestAlertProcessor wires an AlertProcessor to in-memory fakes, with
+// the flap detector disabled.
+func newTestAlertProcessor(alerts *fakeAlertManager) (*AlertProcessor, *fakeNotificationManager) {
+    notifications := &fakeNotificationManager{}
+    incidentService := NewIncidentService(&fakeIncidentManager{})
+    esp32Service := NewESP32Service(fakeESP32Repository{}, nil, incidentService, alerts)
+    p := NewAlertProcessor(
+        esp32Service,
+        notifications,
+        NewEscalationService(fakeEscalationManager{}, incidentService, esp32Service, nil),
+        NewAlertHub(10),
+        NewFlapDetector(FlapConfig{}),
+    )
+    return p, notifications
+}
+
+func TestAlertProcessorBouncedActivationIsNotifiedOnce(t *testing.T) {
+    p, notifications := newTestAlertProcessor(&fakeAlertManager{records: make(map[int]domain.AlertRecord)})
+    settleAt := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+    bounce := domain.FlapState{Suppress: true, SettleAt: settleAt}
+
+    // A bounced activation opens an incident, so it is notified right away
+    // and not held to be notified again.
+    activation := &domain.AlertRecord{ID: 1, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}}
+    result, err := p.process(activation, bounce)
+    if err != nil {
+        t.Fatalf("process(activation) error = %v", err)
+    }
+    if !result.IncidentOpened || !result.Notify() {
+        t.Fatalf("activation result = %+v, want a notified new incident", result)
+    }
+    if activation.SettleAt != nil {
+        t.Errorf("activation held until %v, want it not held", activation.SettleAt)
+    }
+    if !activation.NotifyPending || !activation.IncidentOpened {
+        t.Errorf("activation checkpointed with %+v, want its notification pending", activation)
+    }
+
+    // The bounced deactivation leaves the incident open and is held.
+    deactivation := &domain.AlertRecord{ID: 2, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 0}}
+    result, err = p.process(deactivation, bounce)
+    if err != nil {
+        t.Fatalf("process(deactivation) error = %v", err)
+    }
+    if result.Notify() || deactivation.SettleAt == nil || !deactivation.SettleAt.Equal(settleAt) {
+        t.Fatalf("deactivation result = %+v, SettleAt %v, want it held until %v", result, deactivation.SettleAt, settleAt)
+    }
+
+    // Once settled it resolves the incident and is notified.
+    result, err = p.Settle(deactivation)
+    if err != nil {
+        t.Fatalf("Settle() error = %v", err)
+    }
+    if result.Incident == nil || result.Incident.Status != domain.IncidentResolved || !result.Notify() {
+        t.Fatalf("settle result = %+v, want the incident resolved and notified", result)
+    }
+    if deactivation.SettleAt != nil {
+        t.Errorf("settled deactivation still held until %v", deactivation.SettleAt)
+    }
+
+    var sent []int
+    for _, n := range notifications.notifications {
+        if n.Suppressed == "" {
+            sent = append(sent, n.Alert.Estado)
+        }
+    }
+    if len(sent) != 2 || sent[0] != 1 || sent[1] != 0 {
+        t.Errorf("notified estados %v, want the activation and the settled deactivation once each", sent)
+    }
+}
@@ -0,0 +1,400 @@
+package application
+
+import (
+    "errors"
+    "hash/fnv"
+    "log"
+    "sync"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "time"
+)
+
+var (
+    ErrAlertNotFound    = errors.New("alerta no encontrada")
+    ErrAlertQueueClosed = errors.New("la cola de alertas está cerrada")
+)
+
+const (
+    alertShardCapacity = 256
+    alertMaxAttempts   = 5
+    alertSweepBatch    = 500
+)
+
+// AlertQueue accepts alerts, stores them and processes them in the
+// background so devices get an answer right away. Alerts are sharded by
+// device over the workers, so the alerts of one device are processed in the
+// order they arrived. Alerts still queued in the database (because the
+// process stopped, a shard was full or processing failed) are picked up
+// again by a periodic sweep, which also runs on Start. Processing saves a
+// checkpoint with the reading (see ESP32Service.ProcessAlert), so a retry of
+// an alert that got that far is not handled again; it is only notified again
+// if the checkpoint left its notification pending. Alerts the
+// flap detector suppressed are stored as flapping pending and replayed by
+// the sweep once they settle.
+//
+// Each job on a shard holds alerts of one device: a single alert, or the
+// batched alerts of the device, which are processed and notified at once.
+// The sweep may merge several batches into one job, so every alert of a job
+// is reloaded and handled by its own status.
+type AlertQueue struct {
+    alertManager  ports.AlertManager
+    processor     *AlertProcessor
+    sweepInterval time.Duration
+    shards        []chan []domain.AlertRecord
+
+    mu      sync.Mutex
+    pending map[int]bool // queued in memory, not to be dispatched again
+
+    closeMu sync.RWMutex
+    closed  bool
+    done    chan struct{}
+    wg      sync.WaitGroup
+}
+
+func NewAlertQueue(alertManager ports.AlertManager, processor *AlertProcessor, workers int, sweepInterval time.Duration) *AlertQueue {
+    if workers <= 0 {
+        workers = 4
+    }
+    shards := make([]chan []domain.AlertRecord, workers)
+    for i := range shards {
+        shards[i] = make(chan []domain.AlertRecord, alertShardCapacity)
+    }
+    return &AlertQueue{
+        alertManager:  alertManager,
+        processor:     processor,
+        sweepInterval: sweepInterval,
+        shards:        shards,
+        pending:       make(map[int]bool),
+        done:          make(chan struct{}),
+    }
+}
+
+// Start runs the workers and the sweep, reloading the alerts left queued.
+func (q *AlertQueue) Start() {
+    for _, shard := range q.shards {
+        q.wg.Add(1)
+        go q.worker(shard)
+    }
+
+    q.wg.Add(1)
+    go func() {
+        defer q.wg.Done()
+
+        ticker := time.NewTicker(q.sweepInterval)
+        defer ticker.Stop()
+        for {
+            q.sweep()
+            select {
+            case <-q.done:
+                return
+            case <-ticker.C:
+            }
+        }
+    }()
+}
+
+// Stop stops accepting alerts and waits for the queued ones to be
+// processed. Alerts that did not fit in memory stay queued in the database.
+func (q *AlertQueue) Stop() {
+    q.closeMu.Lock()
+    if q.closed {
+        q.closeMu.Unlock()
+        return
+    }
+    q.closed = true
+    close(q.done)
+    for _, shard := range q.shards {
+        close(shard)
+    }
+    q.closeMu.Unlock()
+
+    q.wg.Wait()
+}
+
+// Submit validates and stores the alert and queues it for processing.
+// Invalid alerts are rejected right away with the errors of
+// AlertProcessor.Validate. A retry of an alert already stored is not
+// processed again: Submit returns the original record and false.
+func (q *AlertQueue) Submit(alert *domain.Alert) (*domain.AlertRecord, bool, error) {
+    if err := q.processor.Validate(alert); err != nil {
+        return nil, false, err
+    }
+
+    q.closeMu.RLock()
+    defer q.closeMu.RUnlock()
+    if q.closed {
+        return nil, false, ErrAlertQueueClosed
+    }
+
+    record := &domain.AlertRecord{
+        Alert:      *alert,
+        Status:     domain.AlertQueued,
+        ReceivedAt: time.Now().UTC().Truncate(time.Second),
+    }
+    // The sweep may dispatch the new alert first; the worker then finds it
+    // already processed when this dispatch comes up.
+    created, err := q.alertManager.SaveAlert(record)
+    if err != nil {
+        return nil, false, err
+    }
+    if created {
+        q.mu.Lock()
+        q.dispatch([]domain.AlertRecord{*record})
+        q.mu.Unlock()
+    }
+    return record, created, nil
+}
+
+// BatchItemResult is the outcome of one alert of a batch: rejected with Err,
+// or stored as Record, which Created tells apart from a retry.
+type BatchItemResult struct {
+    Record  *domain.AlertRecord
+    Created bool
+    Err     error
+}
+
+// SubmitBatch is Submit for alerts that arrived together, e.g. buffered by a
+// gateway. The valid ones are stored with their readings in a single
+// transaction, and the new ones of each device are notified once.
+func (q *AlertQueue) SubmitBatch(alerts []*domain.Alert) ([]BatchItemResult, error) {
+    results := make([]BatchItemResult, len(alerts))
+    var records []*domain.AlertRecord
+    var indexes []int
+
+    receivedAt := time.Now().UTC().Truncate(time.Second)
+    devices := make(map[string]error)
+    for i, alert := range alerts {
+        err := alert.Validate()
+        if err == nil {
+            deviceErr, seen := devices[alert.NumeroSerie]
+            if !seen {
+                deviceErr = q.processor.Validate(alert)
+                devices[alert.NumeroSerie] = deviceErr
+            }
+            err = deviceErr
+        }
+        if err != nil {
+            results[i].Err = err
+            continue
+        }
+        records = append(records, &domain.AlertRecord{
+            Alert:      *alert,
+            Status:     domain.AlertQueued,
+            ReceivedAt: receivedAt,
+        })
+        indexes = append(indexes, i)
+    }
+    if len(records) == 0 {
+        return results, nil
+    }
+
+    q.closeMu.RLock()
+    defer q.closeMu.RUnlock()
+    if q.closed {
+        return nil, ErrAlertQueueClosed
+    }
+
+    created, err := q.processor.SaveBatch(records)
+    if err != nil {
+        return nil, err
+    }
+
+    var jobs [][]domain.AlertRecord
+    job := make(map[string]int)
+    for i, record := range records {
+        results[indexes[i]] = BatchItemResult{Record: record, Created: created[i]}
+        if !created[i] {
+            continue
+        }
+        serial := record.Alert.NumeroSerie
+        if _, ok := job[serial]; !ok {
+            job[serial] = len(jobs)
+            jobs = append(jobs, nil)
+        }
+        jobs[job[serial]] = append(jobs[job[serial]], *record)
+    }
+    q.mu.Lock()
+    for _, records := range jobs {
+        q.dispatch(records)
+    }
+    q.mu.Unlock()
+    return results, nil
+}
+
+func (q *AlertQueue) Get(id int) (*domain.AlertRecord, error) {
+    record, err := q.alertManager.GetAlert(id)
+    if err != nil {
+        return nil, err
+    }
+    if record == nil {
+        return nil, ErrAlertNotFound
+    }
+    return record, nil
+}
+
+// dispatch queues the records, all of one device, on the shard of the device
+// unless they are already queued or the shard is full. q.mu and q.closeMu
+// must be held.
+func (q *AlertQueue) dispatch(records []domain.AlertRecord) {
+    var job []domain.AlertRecord
+    for _, record := range records {
+        if !q.pending[record.ID] {
+            job = append(job, record)
+        }
+    }
+    if len(job) == 0 {
+        return
+    }
+    h := fnv.New32a()
+    h.Write([]byte(job[0].Alert.NumeroSerie))
+    shard := q.shards[h.Sum32()%uint32(len(q.shards))]
+
+    select {
+    case shard <- job:
+        for _, record := range job {
+            q.pending[record.ID] = true
+        }
+    default:
+        // Left queued in the database for the next sweep.
+    }
+}
+
+// Settle is the settle handler of the FlapDetector: the suppressed alert of
+// the sensor is due, so the sweep runs right away to replay it on the shard
+// of its device.
+func (q *AlertQueue) Settle(serial, sensor string) {
+    q.sweep()
+}
+
+func (q *AlertQueue) sweep() {
+    records, err := q.alertManager.ListQueuedAlerts(alertSweepBatch)
+    if err != nil {
+        log.Printf("Error reading queued alerts: %v", err)
+        return
+    }
+
+    q.closeMu.RLock()
+    defer q.closeMu.RUnlock()
+    if q.closed {
+        return
+    }
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    // The alerts of a batch are dispatched together again, per device; the
+    // flapping pending ones are replayed one by one.
+    batches := make(map[string][]domain.AlertRecord)
+    var serials []string
+    for _, record := range records {
+        if !record.ReadingStored || record.Status == domain.AlertFlappingPending {
+            q.dispatch([]domain.AlertRecord{record})
+            continue
+        }
+        serial := record.Alert.NumeroSerie
+        if _, ok := batches[serial]; !ok {
+            serials = append(serials, serial)
+        }
+        batches[serial] = append(batches[serial], record)
+    }
+    for _, serial := range serials {
+        q.dispatch(batches[serial])
+    }
+}
+
+func (q *AlertQueue) worker(shard chan []domain.AlertRecord) {
+    defer q.wg.Done()
+    for job := range shard {
+        q.process(job)
+
+        q.mu.Lock()
+        for _, record := range job {
+            delete(q.pending, record.ID)
+        }
+        q.mu.Unlock()
+    }
+}
+
+func (q *AlertQueue) process(job []domain.AlertRecord) {
+    // Every alert is reloaded: a job may hold alerts of several batches of
+    // the device, and each may have been processed since it was dispatched,
+    // got to its checkpoint before failing or be held until it settles.
+    var queued []*domain.AlertRecord
+    for _, dispatched := range job {
+        record, err := q.alertManager.GetAlert(dispatched.ID)
+        if err != nil || record == nil {
+            log.Printf("Error reloading alert %d: %v", dispatched.ID, err)
+            continue
+        }
+        if !record.Due(time.Now()) {
+            continue
+        }
+        switch record.Status {
+        case domain.AlertProcessing:
+            if !record.NotifyPending {
+                q.finish(record, nil, nil)
+                continue
+            }
+            result, err := q.processor.Renotify(record)
+            q.finish(record, result, err)
+        case domain.AlertFlappingPending:
+            result, err := q.processor.Settle(record)
+            q.finish(record, result, err)
+        default:
+            queued = append(queued, record)
+        }
+    }
+    if len(queued) == 0 {
+        return
+    }
+
+    var result *ProcessedAlert
+    var err error
+    if len(queued) == 1 && !queued[0].ReadingStored {
+        result, err = q.processor.Process(queued[0])
+    } else {
+        result, err = q.processor.ProcessBatch(queued)
+    }
+    for _, record := range queued {
+        q.finish(record, result, err)
+    }
+}
+
+// finish records the outcome of processing the alert. A nil result with no
+// error marks processed an alert found at its checkpoint with nothing left
+// to notify. An alert processing held until it settles (see
+// domain.AlertCheckpoint) stays flapping pending.
+func (q *AlertQueue) finish(record *domain.AlertRecord, result *ProcessedAlert, err error) {
+    record.Attempts++
+
+    switch {
+    case err == nil:
+        now := time.Now().UTC().Truncate(time.Second)
+        record.Status = domain.AlertProcessed
+        record.LastError = ""
+        record.ProcessedAt = &now
+        record.NotifyPending = false
+        if record.SettleAt != nil {
+            record.Status = domain.AlertFlappingPending
+        }
+        if result != nil {
+            record.Delivery = &result.Delivery
+            record.IncidentID = incidentID(result.Incident)
+        }
+    case record.Attempts >= alertMaxAttempts,
+        errors.Is(err, domain.ErrInvalidAlert),
+        errors.Is(err, ErrUnknownDevice):
+        record.Status = domain.AlertFailed
+        record.LastError = err.Error()
+    default:
+        // Still queued, or flapping pending: the sweep retries it.
+        record.LastError = err.Error()
+    }
+    if err != nil {
+        log.Printf("Error processing alert %d (attempt %d): %v", record.ID, record.Attempts, err)
+    }
+
+    if err := q.alertManager.UpdateAlert(record); err != nil {
+        log.Printf("Error saving alert %d: %v", record.ID, err)
+    }
+}
@@ -0,0 +1,161 @@
+package application
+
+import (
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "testing"
+    "time"
+)
+
+// fakeAlertManager keeps the alert records in memory. Methods the tests do
+// not need panic through the nil embedded interface.
+type fakeAlertManager struct {
+    ports.AlertManager
+    records     map[int]domain.AlertRecord
+    updates     []domain.AlertRecord
+    checkpoints []domain.AlertCheckpoint
+}
+
+func (m *fakeAlertManager) GetAlert(id int) (*domain.AlertRecord, error) {
+    record, ok := m.records[id]
+    if !ok {
+        return nil, nil
+    }
+    return &record, nil
+}
+
+func (m *fakeAlertManager) UpdateAlert(record *domain.AlertRecord) error {
+    m.records[record.ID] = *record
+    m.updates = append(m.updates, *record)
+    return nil
+}
+
+// The queue has no processor in these tests: processing the alert again
+// would panic.
+func TestAlertQueueProcessResumesFromCheckpoint(t *testing.T) {
+    alert := domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}
+    later := time.Now().Add(time.Hour)
+
+    tests := []struct {
+        name       string
+        stored     domain.AlertRecord
+        wantUpdate bool
+    }{
+        {
+            name:       "checkpointed alert is only marked processed",
+            stored:     domain.AlertRecord{ID: 1, Alert: alert, Status: domain.AlertProcessing, IncidentID: 7, Attempts: 1},
+            wantUpdate: true,
+        },
+        {
+            name:   "alert processed since it was dispatched is skipped",
+            stored: domain.AlertRecord{ID: 1, Alert: alert, Status: domain.AlertProcessed, IncidentID: 7},
+        },
+        {
+            name:   "failed alert is skipped",
+            stored: domain.AlertRecord{ID: 1, Alert: alert, Status: domain.AlertFailed},
+        },
+        {
+            name:   "flapping pending alert is skipped until it settles",
+            stored: domain.AlertRecord{ID: 1, Alert: alert, Status: domain.AlertFlappingPending, SettleAt: &later},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            manager := &fakeAlertManager{records: map[int]domain.AlertRecord{1: tt.stored}}
+            q := NewAlertQueue(manager, nil, 1, 0)
+
+            // The dispatched copy is older than the stored record.
+            q.process([]domain.AlertRecord{{ID: 1, Alert: alert, Status: domain.AlertQueued}})
+
+            if !tt.wantUpdate {
+                if len(manager.updates) != 0 {
+                    t.Errorf("updated %+v, want no update", manager.updates)
+                }
+                return
+            }
+            if len(manager.updates) != 1 {
+                t.Fatalf("got %d updates, want 1", len(manager.updates))
+            }
+            got := manager.updates[0]
+            if got.Status != domain.AlertProcessed {
+                t.Errorf("Status = %q, want %q", got.Status, domain.AlertProcessed)
+            }
+            if got.IncidentID != 7 {
+                t.Errorf("IncidentID = %d, want 7", got.IncidentID)
+            }
+            if got.Attempts != 2 {
+                t.Errorf("Attempts = %d, want 2", got.Attempts)
+            }
+            if got.Delivery != nil {
+                t.Errorf("Delivery = %+v, want none", got.Delivery)
+            }
+        })
+    }
+}
+
+func TestAlertQueueProcessesEachBatchOfAMergedJob(t *testing.T) {
+    serial := "ESP32-001"
+    manager := &fakeAlertManager{records: map[int]domain.AlertRecord{
+        // The first batch got to its checkpoint before the process stopped.
+        1: {ID: 1, Alert: domain.Alert{NumeroSerie: serial, Sensor: "KY_026", Estado: 1}, Status: domain.AlertProcessing, IncidentID: 7, ReadingStored: true},
+        2: {ID: 2, Alert: domain.Alert{NumeroSerie: serial, Sensor: "KY_026", Estado: 1}, Status: domain.AlertProcessing, IncidentID: 7, ReadingStored: true},
+        // The second one is still queued.
+        3: {ID: 3, Alert: domain.Alert{NumeroSerie: serial, Sensor: "MQ_2", Estado: 1}, Status: domain.AlertQueued, ReadingStored: true},
+    }}
+    processor, notifications := newTestAlertProcessor(manager)
+    q := NewAlertQueue(manager, processor, 1, 0)
+
+    // The sweep merges the batches of the device into one job.
+    var job []domain.AlertRecord
+    for id := 1; id <= 3; id++ {
+        record := manager.records[id]
+        record.Status = domain.AlertQueued
+        job = append(job, record)
+    }
+    q.process(job)
+
+    if len(manager.checkpoints) != 1 {
+        t.Fatalf("got %d checkpoints, want 1 for the queued batch", len(manager.checkpoints))
+    }
+    if records := manager.checkpoints[0].Records; len(records) != 1 || records[0].ID != 3 {
+        t.Errorf("checkpoint of %+v, want only alert 3", records)
+    }
+    if len(notifications.notifications) != 1 || notifications.notifications[0].Alert.Sensor != "MQ_2" {
+        t.Errorf("notifications = %+v, want one for alert 3", notifications.notifications)
+    }
+    for id := 1; id <= 3; id++ {
+        if got := manager.records[id]; got.Status != domain.AlertProcessed {
+            t.Errorf("alert %d Status = %q, want %q", id, got.Status, domain.AlertProcessed)
+        }
+    }
+    if got := manager.records[3].IncidentID; got == 7 || got == 0 {
+        t.Errorf("alert 3 IncidentID = %d, want the incident of its own sensor", got)
+    }
+}
+
+func TestAlertQueueProcessRenotifiesPendingCheckpoint(t *testing.T) {
+    alert := domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}
+    manager := &fakeAlertManager{records: map[int]domain.AlertRecord{
+        1: {ID: 1, Alert: alert, Status: domain.AlertProcessing, NotifyPending: true, IncidentOpened: true, OutboxQueued: 1},
+    }}
+    processor, notifications := newTestAlertProcessor(manager)
+    q := NewAlertQueue(manager, processor, 1, 0)
+
+    q.process([]domain.AlertRecord{{ID: 1, Alert: alert, Status: domain.AlertQueued}})
+
+    if len(manager.checkpoints) != 0 {
+        t.Errorf("got %d checkpoints, want the alert not handled again", len(manager.checkpoints))
+    }
+    if len(notifications.notifications) != 1 {
+        t.Fatalf("got %d notifications, want the pending one sent", len(notifications.notifications))
+    }
+    n := notifications.notifications[0]
+    if !n.IncidentOpened || n.OutboxQueued != 1 || n.Alert.NumeroSerie != alert.NumeroSerie {
+        t.Errorf("notification = %+v, want the one of the checkpoint", n)
+    }
+    got := manager.records[1]
+    if got.Status != domain.AlertProcessed || got.NotifyPending || got.Delivery == nil {
+        t.Errorf("record = %+v, want it processed with its delivery and nothing pending", got)
+    }
+}
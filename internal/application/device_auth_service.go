@@ -0,0 +1,150 @@
+package application
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "strconv"
+    "sync"
+    "telegramassist/internal/domain"
+    "time"
+)
+
+var (
+    ErrMissingSignature     = errors.New("missing device signature headers")
+    ErrInvalidTimestamp     = errors.New("invalid signature timestamp")
+    ErrTimestampOutOfRange  = errors.New("signature timestamp outside the allowed window")
+    ErrInvalidSignature     = errors.New("invalid device signature")
+    ErrReplayedNonce        = errors.New("nonce already used")
+    ErrUnknownDevice        = errors.New("unknown device")
+    ErrDeviceNotProvisioned = errors.New("device has no active credentials")
+    ErrCredentialInactive   = errors.New("credential revoked or expired")
+    ErrSerialMismatch       = errors.New("alert serial does not match the signing device")
+)
+
+// DeviceSignature holds the authentication headers sent by an ESP32 with
+// every alert. Signature is the hex encoded HMAC-SHA256 of
+// "<timestamp>.<nonce>.<body>" using the device secret. KeyID is optional;
+// without it every active credential of the device is tried.
+type DeviceSignature struct {
+    Serial    string
+    KeyID     string
+    Timestamp string
+    Nonce     string
+    Signature string
+}
+
+type DeviceAuthService struct {
+    esp32Service *ESP32Service
+    window       time.Duration
+    nonces       *nonceCache
+    now          func() time.Time
+}
+
+func NewDeviceAuthService(esp32Service *ESP32Service, window time.Duration) *DeviceAuthService {
+    return &DeviceAuthService{
+        esp32Service: esp32Service,
+        window:       window,
+        nonces:       newNonceCache(),
+        now:          time.Now,
+    }
+}
+
+// Verify checks the signature of body against the secret of the device that
+// claims to have sent it and rejects requests that are too old or replayed.
+func (s *DeviceAuthService) Verify(sig DeviceSignature, body []byte) error {
+    if sig.Serial == "" || sig.Timestamp == "" || sig.Nonce == "" || sig.Signature == "" {
+        return ErrMissingSignature
+    }
+
+    unix, err := strconv.ParseInt(sig.Timestamp, 10, 64)
+    if err != nil {
+        return ErrInvalidTimestamp
+    }
+    now := s.now()
+    sentAt := time.Unix(unix, 0)
+    if sentAt.Before(now.Add(-s.window)) || sentAt.After(now.Add(s.window)) {
+        return ErrTimestampOutOfRange
+    }
+
+    credentials, err := s.esp32Service.GetActiveCredentials(sig.Serial, now)
+    if err != nil {
+        return err
+    }
+    if len(credentials) == 0 {
+        return ErrDeviceNotProvisioned
+    }
+
+    if err := verifyWithCredentials(credentials, sig, body); err != nil {
+        return err
+    }
+
+    // The nonce is only recorded once the signature is valid so that
+    // unauthenticated requests cannot fill the cache.
+    if !s.nonces.add(sig.Serial+":"+sig.Nonce, sentAt.Add(s.window), now) {
+        return ErrReplayedNonce
+    }
+    return nil
+}
+
+func verifyWithCredentials(credentials []domain.DeviceCredential, sig DeviceSignature, body []byte) error {
+    matched := false
+    for _, credential := range credentials {
+        if sig.KeyID != "" && credential.KeyID != sig.KeyID {
+            continue
+        }
+        matched = true
+        if validSignature(credential.Secret, sig, body) {
+            return nil
+        }
+    }
+    if !matched {
+        return ErrCredentialInactive
+    }
+    return ErrInvalidSignature
+}
+
+func validSignature(secret string, sig DeviceSignature, body []byte) bool {
+    expected, err := hex.DecodeString(sig.Signature)
+    if err != nil {
+        return false
+    }
+
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(sig.Timestamp + "." + sig.Nonce + "."))
+    mac.Write(body)
+    return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// nonceCache remembers the nonces seen inside the signature window.
+type nonceCache struct {
+    mu        sync.Mutex
+    entries   map[string]time.Time
+    lastPrune time.Time
+}
+
+func newNonceCache() *nonceCache {
+    return &nonceCache{entries: make(map[string]time.Time)}
+}
+
+// add stores key until expiresAt and reports false if it was already present.
+func (c *nonceCache) add(key string, expiresAt, now time.Time) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if now.Sub(c.lastPrune) > time.Minute {
+        for k, exp := range c.entries {
+            if now.After(exp) {
+                delete(c.entries, k)
+            }
+        }
+        c.lastPrune = now
+    }
+
+    if exp, ok := c.entries[key]; ok && !now.After(exp) {
+        return false
+    }
+    c.entries[key] = expiresAt
+    return true
+}
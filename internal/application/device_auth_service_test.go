@@ -0,0 +1,109 @@
+package application
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "strconv"
+    "telegramassist/internal/domain"
+    "testing"
+    "time"
+)
+
+// fakeESP32Repo serves devices and credentials from memory. Methods the
+// tests do not need panic through the nil embedded interface.
+type fakeESP32Repo struct {
+    domain.ESP32Repository
+    devices     map[string]*domain.ESP32
+    credentials map[string][]domain.DeviceCredential
+}
+
+func (r *fakeESP32Repo) GetBySerial(serial string) (*domain.ESP32, error) {
+    return r.devices[serial], nil
+}
+
+func (r *fakeESP32Repo) GetActiveCredentials(serial string, at time.Time) ([]domain.DeviceCredential, error) {
+    var active []domain.DeviceCredential
+    for _, credential := range r.credentials[serial] {
+        if credential.ActiveAt(at) {
+            active = append(active, credential)
+        }
+    }
+    return active, nil
+}
+
+func sign(secret string, sig DeviceSignature, body []byte) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(sig.Timestamp + "." + sig.Nonce + "."))
+    mac.Write(body)
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestDeviceAuthServiceVerify(t *testing.T) {
+    now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+    expired := now.Add(-time.Hour)
+    repo := &fakeESP32Repo{
+        devices: map[string]*domain.ESP32{
+            "ESP-1": {Serial: "ESP-1"},
+            "ESP-2": {Serial: "ESP-2"},
+        },
+        credentials: map[string][]domain.DeviceCredential{
+            "ESP-1": {
+                {KeyID: "k1", Secret: "old", ExpiresAt: &expired},
+                {KeyID: "k2", Secret: "current"},
+            },
+        },
+    }
+    body := []byte(`{"numeroSerie":"ESP-1"}`)
+    ts := strconv.FormatInt(now.Unix(), 10)
+
+    signed := func(serial, keyID, secret, timestamp, nonce string) DeviceSignature {
+        sig := DeviceSignature{Serial: serial, KeyID: keyID, Timestamp: timestamp, Nonce: nonce}
+        sig.Signature = sign(secret, sig, body)
+        return sig
+    }
+
+    tests := []struct {
+        name string
+        sig  DeviceSignature
+        want error
+    }{
+        {"valid", signed("ESP-1", "k2", "current", ts, "n1"), nil},
+        {"valid without key id", signed("ESP-1", "", "current", ts, "n2"), nil},
+        {"missing headers", DeviceSignature{Serial: "ESP-1", Timestamp: ts}, ErrMissingSignature},
+        {"invalid timestamp", signed("ESP-1", "k2", "current", "yesterday", "n3"), ErrInvalidTimestamp},
+        {"too old", signed("ESP-1", "k2", "current", strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10), "n4"), ErrTimestampOutOfRange},
+        {"from the future", signed("ESP-1", "k2", "current", strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10), "n5"), ErrTimestampOutOfRange},
+        {"unknown device", signed("ESP-9", "k2", "current", ts, "n6"), ErrUnknownDevice},
+        {"not provisioned", signed("ESP-2", "k2", "current", ts, "n7"), ErrDeviceNotProvisioned},
+        {"expired key", signed("ESP-1", "k1", "old", ts, "n8"), ErrCredentialInactive},
+        {"wrong secret", signed("ESP-1", "k2", "guess", ts, "n9"), ErrInvalidSignature},
+        {"replayed nonce", signed("ESP-1", "k2", "current", ts, "n1"), ErrReplayedNonce},
+    }
+
+    service := NewDeviceAuthService(NewESP32Service(repo, nil, nil, nil), 5*time.Minute)
+    service.now = func() time.Time { return now }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := service.Verify(tt.sig, body)
+            if !errors.Is(err, tt.want) {
+                t.Fatalf("Verify() = %v, want %v", err, tt.want)
+            }
+        })
+    }
+}
+
+func TestDeviceAuthServiceVerifyTamperedBody(t *testing.T) {
+    now := time.Now()
+    repo := &fakeESP32Repo{credentials: map[string][]domain.DeviceCredential{
+        "ESP-1": {{KeyID: "k1", Secret: "secret"}},
+    }}
+    service := NewDeviceAuthService(NewESP32Service(repo, nil, nil, nil), 5*time.Minute)
+
+    sig := DeviceSignature{Serial: "ESP-1", Timestamp: strconv.FormatInt(now.Unix(), 10), Nonce: "n"}
+    sig.Signature = sign("secret", sig, []byte(`{"estado":0}`))
+    if err := service.Verify(sig, []byte(`{"estado":1}`)); !errors.Is(err, ErrInvalidSignature) {
+        t.Fatalf("Verify() = %v, want %v", err, ErrInvalidSignature)
+    }
+}
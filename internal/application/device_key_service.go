@@ -0,0 +1,124 @@
+package application
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "time"
+)
+
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// DeviceKeyService issues, rotates and revokes the credentials ESP32 devices
+// use to sign their alerts.
+type DeviceKeyService struct {
+    esp32Service      *ESP32Service
+    credentialManager ports.DeviceCredentialManager
+    defaultOverlap    time.Duration
+    now               func() time.Time
+}
+
+func NewDeviceKeyService(esp32Service *ESP32Service, credentialManager ports.DeviceCredentialManager, defaultOverlap time.Duration) *DeviceKeyService {
+    return &DeviceKeyService{
+        esp32Service:      esp32Service,
+        credentialManager: credentialManager,
+        defaultOverlap:    defaultOverlap,
+        now:               time.Now,
+    }
+}
+
+// Issue creates a new credential without touching the existing ones.
+func (s *DeviceKeyService) Issue(serial string) (*domain.DeviceCredential, error) {
+    if err := s.ensureDevice(serial); err != nil {
+        return nil, err
+    }
+    return s.create(serial)
+}
+
+func (s *DeviceKeyService) List(serial string) ([]domain.DeviceCredential, error) {
+    if err := s.ensureDevice(serial); err != nil {
+        return nil, err
+    }
+    return s.credentialManager.ListCredentials(serial)
+}
+
+// Rotate issues a new credential and lets the current ones keep working for
+// the overlap period so the device can be reconfigured without losing alerts.
+// A zero overlap uses the service default.
+func (s *DeviceKeyService) Rotate(serial string, overlap time.Duration) (*domain.DeviceCredential, time.Time, error) {
+    if err := s.ensureDevice(serial); err != nil {
+        return nil, time.Time{}, err
+    }
+    if overlap <= 0 {
+        overlap = s.defaultOverlap
+    }
+
+    overlapEnds := s.now().Add(overlap)
+    if err := s.credentialManager.ExpireCredentials(serial, overlapEnds); err != nil {
+        return nil, time.Time{}, err
+    }
+
+    credential, err := s.create(serial)
+    if err != nil {
+        return nil, time.Time{}, err
+    }
+    return credential, overlapEnds, nil
+}
+
+func (s *DeviceKeyService) Revoke(serial string, keyID string) error {
+    if err := s.ensureDevice(serial); err != nil {
+        return err
+    }
+
+    revoked, err := s.credentialManager.RevokeCredential(serial, keyID, s.now())
+    if err != nil {
+        return err
+    }
+    if !revoked {
+        return ErrCredentialNotFound
+    }
+    return nil
+}
+
+func (s *DeviceKeyService) ensureDevice(serial string) error {
+    esp32, err := s.esp32Service.GetDevice(serial)
+    if err != nil {
+        return err
+    }
+    if esp32 == nil {
+        return ErrUnknownDevice
+    }
+    return nil
+}
+
+func (s *DeviceKeyService) create(serial string) (*domain.DeviceCredential, error) {
+    keyID, err := randomHex(8)
+    if err != nil {
+        return nil, err
+    }
+    secret, err := randomHex(32)
+    if err != nil {
+        return nil, err
+    }
+
+    credential := &domain.DeviceCredential{
+        ESP32Serial: serial,
+        KeyID:       keyID,
+        Secret:      secret,
+        CreatedAt:   s.now().UTC().Truncate(time.Second),
+    }
+    if err := s.credentialManager.CreateCredential(credential); err != nil {
+        return nil, err
+    }
+    return credential, nil
+}
+
+func randomHex(n int) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
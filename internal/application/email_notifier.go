@@ -0,0 +1,100 @@
+package application
+
+import (
+    "bytes"
+    "embed"
+    "fmt"
+    htmltemplate "html/template"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    texttemplate "text/template"
+)
+
+//go:embed templates/alert_email.txt templates/alert_email.html
+var emailTemplates embed.FS
+
+var (
+    alertTextTemplate = texttemplate.Must(texttemplate.ParseFS(emailTemplates, "templates/alert_email.txt"))
+    alertHTMLTemplate = htmltemplate.Must(htmltemplate.ParseFS(emailTemplates, "templates/alert_email.html"))
+)
+
+// alertEmailData is what the alert email templates can use.
+type alertEmailData struct {
+    Username           string
+    Serial             string
+    Sensor             string
+    Estado             string
+    Severity           string
+    FechaActivacion    string
+    FechaDesactivacion string
+    IncidentID         int
+    IncidentStatus     string
+}
+
+// EmailNotifier emails the owner of a device when it sends an alert, if the
+// owner enabled email in their notification preferences.
+type EmailNotifier struct {
+    sender ports.EmailSender
+}
+
+func NewEmailNotifier(sender ports.EmailSender) *EmailNotifier {
+    return &EmailNotifier{sender: sender}
+}
+
+func (n *EmailNotifier) Name() string {
+    return "email"
+}
+
+func (n *EmailNotifier) Deliver(notification domain.AlertNotification) domain.ChannelReport {
+    owner := notification.Owner
+    switch {
+    case owner == nil:
+        return domain.SkippedReport(n.Name(), "device has no owner")
+    case !notification.Preferences.EnableEmail:
+        return domain.SkippedReport(n.Name(), "disabled in preferences")
+    case owner.Email == "":
+        return domain.SkippedReport(n.Name(), "owner has no email")
+    }
+
+    report := domain.ChannelReport{Channel: n.Name()}
+    message, err := renderAlertEmail(owner, notification.Alert, notification.Incident)
+    if err == nil {
+        err = n.sender.SendEmail(message)
+    }
+    report.Record(err)
+    return report.Finish(domain.DeliverySent)
+}
+
+func renderAlertEmail(user *domain.User, alert *domain.Alert, incident *domain.Incident) (domain.EmailMessage, error) {
+    data := alertEmailData{
+        Username:           user.Username,
+        Serial:             alert.NumeroSerie,
+        Sensor:             alert.Sensor,
+        Estado:             "desactivado",
+        Severity:           string(domain.AlertSeverity(alert)),
+        FechaActivacion:    alert.FechaActivacion,
+        FechaDesactivacion: alert.FechaDesactivacion,
+    }
+    if alert.Estado == 1 {
+        data.Estado = "activado"
+    }
+    if incident != nil {
+        data.IncidentID = incident.ID
+        data.IncidentStatus = incidentStatusText[incident.Status]
+    }
+
+    var text, html bytes.Buffer
+    if err := alertTextTemplate.Execute(&text, data); err != nil {
+        return domain.EmailMessage{}, err
+    }
+    if err := alertHTMLTemplate.Execute(&html, data); err != nil {
+        return domain.EmailMessage{}, err
+    }
+
+    return domain.EmailMessage{
+        To:       user.Email,
+        Subject:  fmt.Sprintf("🚨 Sensor %s %s en %s", alert.Sensor, data.Estado, alert.NumeroSerie),
+        TextBody: text.String(),
+        HTMLBody: html.String(),
+    }, nil
+}
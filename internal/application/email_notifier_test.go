@@ -0,0 +1,80 @@
+package application
+
+import (
+    "strings"
+    "telegramassist/internal/domain"
+    "testing"
+)
+
+func TestRenderAlertEmail(t *testing.T) {
+    owner := &domain.User{Username: "ana", Email: "ana@example.com"}
+
+    tests := []struct {
+        name      string
+        user      *domain.User
+        alert     *domain.Alert
+        incident  *domain.Incident
+        subject   string
+        text      []string
+        html      []string
+        notInText []string
+    }{
+        {
+            name:      "flame sensor activated with incident",
+            user:      owner,
+            alert:     &domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1, FechaActivacion: "2024-05-01 10:00:00"},
+            incident:  &domain.Incident{ID: 7, Status: domain.IncidentOpen},
+            subject:   "🚨 Sensor KY_026 activado en ESP32-001",
+            text:      []string{"Hola ana,", "se ha activado", "Severidad: high", "Activación: 2024-05-01 10:00:00", "Incidente #7: Abierto"},
+            html:      []string{"#c0392b", "<strong>KY_026</strong>", "#7: Abierto"},
+            notInText: []string{"Desactivación:"},
+        },
+        {
+            name:      "sensor deactivated without incident",
+            user:      owner,
+            alert:     &domain.Alert{NumeroSerie: "ESP32-002", Sensor: "MQ_2", Estado: 0, FechaActivacion: "10:00", FechaDesactivacion: "10:05"},
+            subject:   "🚨 Sensor MQ_2 desactivado en ESP32-002",
+            text:      []string{"se ha desactivado", "Severidad: low", "Desactivación: 10:05"},
+            html:      []string{"#d68910", "<td>Desactivación</td><td>10:05</td>"},
+            notInText: []string{"Incidente #"},
+        },
+        {
+            name:    "html escapes user data",
+            user:    &domain.User{Username: "<b>eve</b>", Email: "eve@example.com"},
+            alert:   &domain.Alert{NumeroSerie: "ESP32-003", Sensor: "KY_026", Estado: 1},
+            subject: "🚨 Sensor KY_026 activado en ESP32-003",
+            text:    []string{"Hola <b>eve</b>,"},
+            html:    []string{"Hola &lt;b&gt;eve&lt;/b&gt;,"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            message, err := renderAlertEmail(tt.user, tt.alert, tt.incident)
+            if err != nil {
+                t.Fatalf("renderAlertEmail() error = %v", err)
+            }
+            if message.To != tt.user.Email {
+                t.Errorf("To = %q, want %q", message.To, tt.user.Email)
+            }
+            if message.Subject != tt.subject {
+                t.Errorf("Subject = %q, want %q", message.Subject, tt.subject)
+            }
+            for _, want := range tt.text {
+                if !strings.Contains(message.TextBody, want) {
+                    t.Errorf("text body does not contain %q:\n%s", want, message.TextBody)
+                }
+            }
+            for _, unwanted := range tt.notInText {
+                if strings.Contains(message.TextBody, unwanted) {
+                    t.Errorf("text body contains %q:\n%s", unwanted, message.TextBody)
+                }
+            }
+            for _, want := range tt.html {
+                if !strings.Contains(message.HTMLBody, want) {
+                    t.Errorf("HTML body does not contain %q:\n%s", want, message.HTMLBody)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,200 @@
+package application
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "sync"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "time"
+)
+
+// EscalationService walks the escalation ladder of incidents nobody has
+// acknowledged. Pending steps live in the database so they are reloaded by
+// Start after a restart; the in-memory timers only decide when to run them.
+type EscalationService struct {
+    escalationManager   ports.EscalationManager
+    incidentService     *IncidentService
+    esp32Service        *ESP32Service
+    notificationService *NotificationService
+    httpClient          *http.Client
+
+    mu     sync.Mutex
+    timers map[int]*time.Timer
+}
+
+func NewEscalationService(
+    escalationManager ports.EscalationManager,
+    incidentService *IncidentService,
+    esp32Service *ESP32Service,
+    notificationService *NotificationService,
+) *EscalationService {
+    return &EscalationService{
+        escalationManager:   escalationManager,
+        incidentService:     incidentService,
+        esp32Service:        esp32Service,
+        notificationService: notificationService,
+        httpClient:          &http.Client{Timeout: 10 * time.Second},
+        timers:              make(map[int]*time.Timer),
+    }
+}
+
+// Start reloads the escalations that were pending when the process stopped.
+// Overdue ones run right away.
+func (s *EscalationService) Start() error {
+    pending, err := s.escalationManager.ListPendingEscalations()
+    if err != nil {
+        return err
+    }
+    for _, escalation := range pending {
+        s.arm(escalation)
+    }
+    log.Printf("Escalamientos pendientes recargados: %d", len(pending))
+    return nil
+}
+
+// Stop cancels the timers; pending escalations stay in the database.
+func (s *EscalationService) Stop() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for id, timer := range s.timers {
+        timer.Stop()
+        delete(s.timers, id)
+    }
+}
+
+// Schedule plans the first step of the ladder for a newly opened incident.
+func (s *EscalationService) Schedule(incident *domain.Incident) error {
+    return s.scheduleStep(incident, 0)
+}
+
+func (s *EscalationService) scheduleStep(incident *domain.Incident, step int) error {
+    policy, err := s.escalationManager.GetEscalationPolicy(incident.ESP32Serial)
+    if err != nil || policy == nil {
+        return err
+    }
+
+    steps := policy.Steps()
+    if step >= len(steps) {
+        return nil
+    }
+
+    escalation := domain.PendingEscalation{
+        IncidentID: incident.ID,
+        Step:       step,
+        DueAt:      incident.OpenedAt.Add(steps[step].Delay),
+    }
+    if err := s.escalationManager.SaveEscalation(&escalation); err != nil {
+        return err
+    }
+    s.arm(escalation)
+    return nil
+}
+
+func (s *EscalationService) arm(escalation domain.PendingEscalation) {
+    delay := time.Until(escalation.DueAt)
+    if delay < 0 {
+        delay = 0
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.timers[escalation.ID] = time.AfterFunc(delay, func() {
+        s.mu.Lock()
+        delete(s.timers, escalation.ID)
+        s.mu.Unlock()
+
+        if err := s.run(escalation); err != nil {
+            log.Printf("Error escalating incident %d: %v", escalation.IncidentID, err)
+        }
+    })
+}
+
+func (s *EscalationService) run(escalation domain.PendingEscalation) error {
+    incident, err := s.incidentService.GetIncident(escalation.IncidentID)
+    if err != nil {
+        return err
+    }
+    // Somebody answered (or the device cleared the alarm): stop the ladder.
+    if incident == nil || incident.Status != domain.IncidentOpen {
+        return s.escalationManager.CompleteEscalation(escalation.ID, domain.EscalationCancelled)
+    }
+
+    policy, err := s.escalationManager.GetEscalationPolicy(incident.ESP32Serial)
+    if err != nil {
+        return err
+    }
+    var steps []domain.EscalationStep
+    if policy != nil {
+        steps = policy.Steps()
+    }
+    if escalation.Step >= len(steps) {
+        return s.escalationManager.CompleteEscalation(escalation.ID, domain.EscalationCancelled)
+    }
+
+    status := domain.EscalationDone
+    if err := s.execute(steps[escalation.Step].Action, incident, policy); err != nil {
+        log.Printf("Escalation step %d of incident %d failed: %v", escalation.Step, incident.ID, err)
+        status = domain.EscalationFailed
+    }
+    if err := s.escalationManager.CompleteEscalation(escalation.ID, status); err != nil {
+        return err
+    }
+
+    return s.scheduleStep(incident, escalation.Step+1)
+}
+
+func (s *EscalationService) execute(action domain.EscalationAction, incident *domain.Incident, policy *domain.EscalationPolicy) error {
+    switch action {
+    case domain.EscalationRenotify:
+        chatIDs, err := s.esp32Service.GetLinkedChats(incident.ESP32Serial)
+        if err != nil {
+            return err
+        }
+        return s.remind(chatIDs, incident)
+    case domain.EscalationSecondary:
+        return s.remind(policy.SecondaryChatIDs, incident)
+    case domain.EscalationWebhook:
+        return s.postWebhook(policy.WebhookURL, incident)
+    default:
+        return fmt.Errorf("unknown escalation action %q", action)
+    }
+}
+
+func (s *EscalationService) remind(chatIDs []int64, incident *domain.Incident) error {
+    var lastErr error
+    for _, chatID := range chatIDs {
+        if err := s.notificationService.SendIncidentReminder(chatID, incident); err != nil {
+            lastErr = err
+        }
+    }
+    return lastErr
+}
+
+func (s *EscalationService) postWebhook(url string, incident *domain.Incident) error {
+    body, err := json.Marshal(map[string]interface{}{
+        "incident_id":  incident.ID,
+        "numero_serie": incident.ESP32Serial,
+        "sensor":       incident.Sensor,
+        "status":       incident.Status,
+        "opened_at":    incident.OpenedAt,
+    })
+    if err != nil {
+        return err
+    }
+
+    resp, err := s.httpClient.Post(url, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+    }
+    return nil
+}
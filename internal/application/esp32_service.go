@@ -3,18 +3,40 @@ package application
 import (
 	"errors"
 	"telegramassist/internal/domain"
-	
+	"telegramassist/internal/domain/ports"
+	"time"
 )
 
 type ESP32Service struct {
 	repo domain.ESP32Repository
 	ky026Service *KY026Service
+	incidentService *IncidentService
+	alertManager ports.AlertManager
 }
 
-func NewESP32Service(repo domain.ESP32Repository, ky026Service *KY026Service) *ESP32Service {
+// ProcessedAlert is the outcome of processing an alert: the incident the
+// alert was correlated to, if any, and how it was notified.
+type ProcessedAlert struct {
+	Alert          *domain.Alert // notified: of a batch, the last not suppressed
+	Incident       *domain.Incident
+	IncidentOpened bool
+	OutboxQueued   int
+	Flap           domain.FlapState
+	Delivery       domain.DeliveryReport
+}
+
+// Notify reports whether the alert is to be notified. Suppressed alerts
+// still are when they opened a new incident, so a fire is never silenced.
+func (p *ProcessedAlert) Notify() bool {
+	return !p.Flap.Suppress || p.IncidentOpened
+}
+
+func NewESP32Service(repo domain.ESP32Repository, ky026Service *KY026Service, incidentService *IncidentService, alertManager ports.AlertManager) *ESP32Service {
 	return &ESP32Service{
 		repo: repo,
 		ky026Service: ky026Service,
+		incidentService: incidentService,
+		alertManager: alertManager,
 	}
 }
 
@@ -35,23 +57,271 @@ func (s *ESP32Service) ValidateAndLinkESP32(chatID int64, serial string) (bool,
 	return true, nil
 }
 
+func (s *ESP32Service) GetDevice(serial string) (*domain.ESP32, error) {
+	return s.repo.GetBySerial(serial)
+}
+
+// GetActiveCredentials returns the keys the device may sign with at the given
+// time. Credentials are read on every call so revocations apply immediately.
+func (s *ESP32Service) GetActiveCredentials(serial string, at time.Time) ([]domain.DeviceCredential, error) {
+	active, err := s.repo.GetActiveCredentials(serial, at)
+	if err != nil || len(active) > 0 {
+		return active, err
+	}
+
+	// Without credentials, tell an unknown device from one not provisioned.
+	esp32, err := s.repo.GetBySerial(serial)
+	if err != nil {
+		return nil, err
+	}
+	if esp32 == nil {
+		return nil, ErrUnknownDevice
+	}
+	return nil, nil
+}
+
 func (s *ESP32Service) GetLastKY026Reading(serial string) (*domain.KY026Reading, error) {
     return s.ky026Service.GetLastReading(serial)
 }
 
-func (s *ESP32Service) ProcessAlert(alert *domain.Alert) ([]int64, error) {
-    if alert.Sensor == "KY_026" {
-        if err := s.ky026Service.ProcessKY026Alert(alert); err != nil {
+// ProcessAlert handles the incident of the alert and saves the checkpoint of
+// its record in a single transaction: the reading, the notification for the
+// device owner, which the outbox relay publishes to RabbitMQ afterwards, and
+// the incident. The incident is handled first because whether the alert is
+// notified depends on it (see ProcessedAlert.Notify). A settled alert was
+// already stored when it was suppressed, so only its notification is written;
+// a suppressed one is checkpointed as flapping pending until its SettleAt.
+func (s *ESP32Service) ProcessAlert(record *domain.AlertRecord, flap domain.FlapState) (*ProcessedAlert, error) {
+    alert := &record.Alert
+    incident, opened, err := s.incidentService.HandleAlert(alert, flap)
+    if err != nil {
+        return nil, err
+    }
+    result := &ProcessedAlert{Alert: alert, Incident: incident, IncidentOpened: opened, Flap: flap}
+
+    var outbox []domain.OutboxMessage
+    if result.Notify() {
+        if outbox, err = s.outboxFor(alert); err != nil {
+            return nil, err
+        }
+    }
+
+    // A suppressed alert that opened an incident was notified already, so it
+    // is not held to be notified again when it settles.
+    record.SettleAt = nil
+    if flap.Suppress && !opened {
+        settleAt := flap.SettleAt
+        record.SettleAt = &settleAt
+    }
+    record.OutboxQueued = len(outbox)
+    record.NotifyPending = result.Notify()
+    record.IncidentOpened = opened
+    checkpoint := domain.AlertCheckpoint{
+        Records:    []*domain.AlertRecord{record},
+        IncidentID: incidentID(incident),
+        Reading:    alert,
+        Outbox:     outbox,
+    }
+    if flap.Settled {
+        checkpoint.Reading = nil
+    }
+    if err := s.alertManager.CheckpointAlerts(checkpoint); err != nil {
+        return nil, err
+    }
+
+    result.OutboxQueued = record.OutboxQueued
+    return result, nil
+}
+
+// SaveAlertBatch stores the alerts of a batch with their readings and, for
+// each device that got new alerts, the notification of the last new one for
+// the owner, all in a single transaction. Which alerts are new is only known
+// in the transaction, so the notification of every alert is built.
+func (s *ESP32Service) SaveAlertBatch(records []*domain.AlertRecord) ([]bool, error) {
+    owners := make(map[string]*domain.User)
+    outbox := make([][]domain.OutboxMessage, len(records))
+    for i, record := range records {
+        serial := record.Alert.NumeroSerie
+        owner, ok := owners[serial]
+        if !ok {
+            var err error
+            if owner, err = s.repo.GetUserByESP32Serial(serial); err != nil {
+                return nil, err
+            }
+            owners[serial] = owner
+        }
+        if owner == nil {
+            continue
+        }
+        message, err := domain.NewOutboxMessage(domain.AlertRoutingKey(&record.Alert), domain.NewUserNotification(owner, &record.Alert))
+        if err != nil {
+            return nil, err
+        }
+        outbox[i] = []domain.OutboxMessage{message}
+    }
+    return s.alertManager.SaveAlertBatch(records, outbox)
+}
+
+// ProcessAlertBatch handles the incidents of alerts of one device stored by
+// SaveAlertBatch, in order and with the flap state of each, and saves their
+// checkpoint. The last suppressed alert of each sensor is held until it
+// settles, unless it opened an incident. The result is about the incident
+// the device ended up in, and the batch is notified with its last alert not
+// suppressed; IncidentOpened is set if the batch opened the incident.
+func (s *ESP32Service) ProcessAlertBatch(records []*domain.AlertRecord, flaps []domain.FlapState) (*ProcessedAlert, error) {
+    last := make(map[string]int)
+    for i, record := range records {
+        last[record.Alert.Sensor] = i
+    }
+
+    result := &ProcessedAlert{}
+    for i, record := range records {
+        incident, opened, err := s.incidentService.HandleAlert(&record.Alert, flaps[i])
+        if err != nil {
             return nil, err
         }
+        record.SettleAt = nil
+        if flaps[i].Suppress && !opened && last[record.Alert.Sensor] == i {
+            settleAt := flaps[i].SettleAt
+            record.SettleAt = &settleAt
+        }
+        if incident == nil {
+            continue
+        }
+        if opened {
+            result.IncidentOpened = true
+        } else if result.Incident == nil || incident.ID != result.Incident.ID {
+            result.IncidentOpened = false
+        }
+        result.Incident = incident
+    }
+
+    notified := len(records) - 1
+    for i := len(records) - 1; i >= 0; i-- {
+        if !flaps[i].Suppress {
+            notified = i
+            break
+        }
+    }
+    result.Alert = &records[notified].Alert
+    result.Flap = flaps[notified]
+    for i, record := range records {
+        record.NotifyPending = i == notified && result.Notify()
+        record.IncidentOpened = i == notified && result.IncidentOpened
+    }
+
+    checkpoint := domain.AlertCheckpoint{Records: records, IncidentID: incidentID(result.Incident)}
+    if err := s.alertManager.CheckpointAlerts(checkpoint); err != nil {
+        return nil, err
+    }
+
+    // The notification for the owner was written with the batch.
+    for _, record := range records {
+        result.OutboxQueued += record.OutboxQueued
+    }
+    return result, nil
+}
+
+// GetIncident returns the incident an alert was correlated to.
+func (s *ESP32Service) GetIncident(id int) (*domain.Incident, error) {
+    return s.incidentService.GetIncident(id)
+}
+
+func incidentID(incident *domain.Incident) int {
+    if incident == nil {
+        return 0
     }
+    return incident.ID
+}
 
-    chatIDs, err := s.repo.GetChatsByESP32Serial(alert.NumeroSerie)
+// outboxFor builds the RabbitMQ notification for the owner of the device;
+// devices without an owner produce none.
+func (s *ESP32Service) outboxFor(alert *domain.Alert) ([]domain.OutboxMessage, error) {
+    user, err := s.repo.GetUserByESP32Serial(alert.NumeroSerie)
     if err != nil {
         return nil, err
     }
+    if user == nil {
+        return nil, nil
+    }
+
+    message, err := domain.NewOutboxMessage(domain.AlertRoutingKey(alert), domain.NewUserNotification(user, alert))
+    if err != nil {
+        return nil, err
+    }
+    return []domain.OutboxMessage{message}, nil
+}
+
+// DeviceStatus summarizes the state of a device linked to a chat.
+type DeviceStatus struct {
+	Serial       string
+	LastReading  *domain.KY026Reading
+	OpenIncident *domain.Incident
+}
+
+// GetDevicesByChat returns the serials linked to the chat, or
+// domain.ErrNoDeviceLinked if there are none.
+func (s *ESP32Service) GetDevicesByChat(chatID int64) ([]string, error) {
+	serials, err := s.repo.GetESP32SerialsByChat(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if len(serials) == 0 {
+		return nil, domain.ErrNoDeviceLinked
+	}
+	return serials, nil
+}
+
+// IsLinked reports whether the device is linked to the chat.
+func (s *ESP32Service) IsLinked(chatID int64, serial string) (bool, error) {
+	serials, err := s.repo.GetESP32SerialsByChat(chatID)
+	if err != nil {
+		return false, err
+	}
+	for _, linked := range serials {
+		if linked == serial {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetDeviceStatuses returns the last reading and open incident of every
+// device linked to the chat.
+func (s *ESP32Service) GetDeviceStatuses(chatID int64) ([]DeviceStatus, error) {
+	serials, err := s.GetDevicesByChat(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]DeviceStatus, 0, len(serials))
+	for _, serial := range serials {
+		reading, err := s.ky026Service.GetLastReading(serial)
+		if err != nil {
+			return nil, err
+		}
+		incident, err := s.incidentService.GetOpenIncident(serial, "KY_026")
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, DeviceStatus{Serial: serial, LastReading: reading, OpenIncident: incident})
+	}
+	return statuses, nil
+}
+
+func (s *ESP32Service) UnlinkESP32(chatID int64, serial string) error {
+	unlinked, err := s.repo.UnlinkChatFromESP32(chatID, serial)
+	if err != nil {
+		return err
+	}
+	if !unlinked {
+		return errors.New("el ESP32 no está vinculado a este chat")
+	}
+	return nil
+}
 
-    return chatIDs, nil
+func (s *ESP32Service) GetLinkedChats(serial string) ([]int64, error) {
+	return s.repo.GetChatsByESP32Serial(serial)
 }
 
 // this method to the ESP32Service
@@ -0,0 +1,79 @@
+package application
+
+import (
+    "telegramassist/internal/domain"
+    "testing"
+)
+
+// ownedESP32Repository is fakeESP32Repository with an owner for ESP32-001.
+type ownedESP32Repository struct {
+    fakeESP32Repository
+}
+
+func (ownedESP32Repository) GetUserByESP32Serial(serial string) (*domain.User, error) {
+    if serial != "ESP32-001" {
+        return nil, nil
+    }
+    return &domain.User{ID: 1, Username: "ana"}, nil
+}
+
+// batchAlertManager stores a batch whose first alert was already stored,
+// as the repository does.
+type batchAlertManager struct {
+    fakeAlertManager
+    outbox [][]domain.OutboxMessage
+}
+
+func (m *batchAlertManager) SaveAlertBatch(records []*domain.AlertRecord, outbox [][]domain.OutboxMessage) ([]bool, error) {
+    m.outbox = outbox
+    created := make([]bool, len(records))
+    for i := 1; i < len(records); i++ {
+        created[i] = true
+    }
+    return created, nil
+}
+
+func TestESP32ServiceSaveAlertBatchBuildsTheOutboxOfEveryAlert(t *testing.T) {
+    manager := &batchAlertManager{}
+    s := NewESP32Service(ownedESP32Repository{}, nil, nil, manager)
+    records := []*domain.AlertRecord{
+        {Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}},
+        {Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "MQ_2", Estado: 1}},
+        {Alert: domain.Alert{NumeroSerie: "ESP32-002", Sensor: "KY_026", Estado: 1}},
+    }
+
+    if _, err := s.SaveAlertBatch(records); err != nil {
+        t.Fatalf("SaveAlertBatch() error = %v", err)
+    }
+    if len(manager.outbox) != len(records) {
+        t.Fatalf("got the outbox of %d alerts, want %d", len(manager.outbox), len(records))
+    }
+    // The repository picks the last new alert of each device, so each alert
+    // carries its own message.
+    for i, record := range records[:2] {
+        messages := manager.outbox[i]
+        if len(messages) != 1 || messages[0].RoutingKey != domain.AlertRoutingKey(&record.Alert) {
+            t.Errorf("outbox of alert %d = %+v, want its own message", i, messages)
+        }
+    }
+    if len(manager.outbox[2]) != 0 {
+        t.Errorf("outbox of a device with no owner = %+v, want none", manager.outbox[2])
+    }
+}
+
+func TestESP32ServiceProcessAlertBatchCountsTheOutboxWritten(t *testing.T) {
+    manager := &fakeAlertManager{records: make(map[int]domain.AlertRecord)}
+    s := NewESP32Service(ownedESP32Repository{}, nil, NewIncidentService(&fakeIncidentManager{}), manager)
+    records := []*domain.AlertRecord{
+        {ID: 1, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}},
+        {ID: 2, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}, OutboxQueued: 1},
+    }
+
+    result, err := s.ProcessAlertBatch(records, make([]domain.FlapState, len(records)))
+    if err != nil {
+        t.Fatalf("ProcessAlertBatch() error = %v", err)
+    }
+    if result.OutboxQueued != 1 {
+        t.Errorf("OutboxQueued = %d, want the 1 message written with the batch", result.OutboxQueued)
+    }
+}
@@ -0,0 +1,181 @@
+package application
+
+import (
+    "sync"
+    "telegramassist/internal/domain"
+    "time"
+)
+
+// FlapConfig holds the debounce policy of each sensor type; sensors not
+// listed use Default.
+type FlapConfig struct {
+    Default domain.FlapPolicy
+    Sensors map[string]domain.FlapPolicy
+}
+
+func (c FlapConfig) policy(sensor string) domain.FlapPolicy {
+    if policy, ok := c.Sensors[sensor]; ok {
+        return policy
+    }
+    return c.Default
+}
+
+type flapKey struct {
+    serial string
+    sensor string
+}
+
+// flapTrack is the recent history of one sensor of one device.
+type flapTrack struct {
+    estado     int
+    lastChange time.Time
+    changes    []time.Time // within the policy window
+    flapping   bool
+    held       bool // the last alert was suppressed and waits to settle
+    gen        int  // invalidates the settle timers already armed
+    timer      *time.Timer
+    observed   map[int]domain.FlapState // by alert ID, so retries are not changes
+}
+
+// FlapDetector collapses the rapid toggles of a sensor. A change of estado
+// closer than Debounce to the previous one is a bounce, and a sensor that
+// changes Threshold times within Window is flapping; the alerts of both are
+// suppressed. The last suppressed alert is to be replayed at the SettleAt of
+// its state, once the sensor has been quiet for the longer of the two
+// periods, so the final state is notified once. The alert is stored to be
+// replayed (see domain.AlertFlappingPending); the detector only tells the
+// settle handler when it is due.
+type FlapDetector struct {
+    config FlapConfig
+    now    func() time.Time
+
+    mu      sync.Mutex
+    tracks  map[flapKey]*flapTrack
+    settle  func(serial, sensor string)
+    stopped bool
+}
+
+func NewFlapDetector(config FlapConfig) *FlapDetector {
+    return &FlapDetector{
+        config: config,
+        now:    time.Now,
+        tracks: make(map[flapKey]*flapTrack),
+    }
+}
+
+// SetSettleHandler sets the function called when the suppressed alert of a
+// sensor is due to be replayed.
+func (d *FlapDetector) SetSettleHandler(settle func(serial, sensor string)) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.settle = settle
+}
+
+// Observe records the alert and decides whether it is to be suppressed. An
+// alert observed before, e.g. processed again after a failure, gets the same
+// decision without counting as a change again.
+func (d *FlapDetector) Observe(record *domain.AlertRecord) domain.FlapState {
+    alert := &record.Alert
+    policy := d.config.policy(alert.Sensor)
+    if !policy.Enabled() {
+        return domain.FlapState{}
+    }
+    now := d.now()
+
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if d.stopped {
+        return domain.FlapState{}
+    }
+
+    key := flapKey{alert.NumeroSerie, alert.Sensor}
+    track, seen := d.tracks[key]
+    if !seen {
+        // The first alert after a quiet period is never suppressed.
+        track = &flapTrack{estado: alert.Estado, lastChange: now, observed: make(map[int]domain.FlapState)}
+        d.tracks[key] = track
+    }
+    if state, ok := track.observed[record.ID]; ok {
+        return state
+    }
+
+    var state domain.FlapState
+    if seen && alert.Estado != track.estado {
+        bounce := policy.Debounce > 0 && now.Sub(track.lastChange) < policy.Debounce
+
+        cutoff := now.Add(-policy.Window)
+        kept := track.changes[:0]
+        for _, at := range track.changes {
+            if at.After(cutoff) {
+                kept = append(kept, at)
+            }
+        }
+        track.changes = append(kept, now)
+        if policy.Threshold > 0 && len(track.changes) >= policy.Threshold {
+            track.flapping = true
+        }
+
+        track.estado = alert.Estado
+        track.lastChange = now
+        state.Suppress = bounce || track.flapping
+    } else if seen {
+        state.Suppress = track.flapping
+    }
+    state.Flapping = track.flapping
+    track.held = state.Suppress
+
+    quiet := policy.Debounce
+    if policy.Window > quiet {
+        quiet = policy.Window
+    }
+    // Rounded up to the second it is stored with, so the alert is due when
+    // the timer fires.
+    settleAt := now.Add(quiet).UTC()
+    if rounded := settleAt.Truncate(time.Second); rounded.Before(settleAt) {
+        settleAt = rounded.Add(time.Second)
+    }
+    if state.Suppress {
+        state.SettleAt = settleAt
+    }
+
+    track.gen++
+    gen := track.gen
+    if track.timer != nil {
+        track.timer.Stop()
+    }
+    track.timer = time.AfterFunc(settleAt.Sub(now), func() { d.settleTrack(key, gen) })
+
+    track.observed[record.ID] = state
+    return state
+}
+
+// settleTrack forgets a sensor that has been quiet and tells the settle
+// handler if it had suppressed its last alert.
+func (d *FlapDetector) settleTrack(key flapKey, gen int) {
+    d.mu.Lock()
+    track, ok := d.tracks[key]
+    if !ok || track.gen != gen || d.stopped {
+        d.mu.Unlock()
+        return
+    }
+    delete(d.tracks, key)
+    held, settle := track.held, d.settle
+    d.mu.Unlock()
+
+    if held && settle != nil {
+        settle(key.serial, key.sensor)
+    }
+}
+
+// Stop cancels the pending settles. The suppressed alerts are stored, so the
+// periodic sweep of the AlertQueue replays them once they are due.
+func (d *FlapDetector) Stop() {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.stopped = true
+    for _, track := range d.tracks {
+        if track.timer != nil {
+            track.timer.Stop()
+        }
+    }
+}
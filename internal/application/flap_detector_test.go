@@ -0,0 +1,159 @@
+package application
+
+import (
+    "telegramassist/internal/domain"
+    "testing"
+    "time"
+)
+
+func TestFlapDetectorObserve(t *testing.T) {
+    start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+    policy := domain.FlapPolicy{Debounce: 2 * time.Second, Window: 10 * time.Second, Threshold: 3}
+
+    type step struct {
+        after    time.Duration // since start
+        estado   int
+        suppress bool
+        flapping bool
+    }
+    tests := []struct {
+        name  string
+        steps []step
+    }{
+        {
+            name: "first alert is never suppressed",
+            steps: []step{
+                {after: 0, estado: 1},
+            },
+        },
+        {
+            name: "slow changes are notified",
+            steps: []step{
+                {after: 0, estado: 1},
+                {after: 5 * time.Second, estado: 0},
+            },
+        },
+        {
+            name: "change within the debounce is a bounce",
+            steps: []step{
+                {after: 0, estado: 1},
+                {after: time.Second, estado: 0, suppress: true},
+            },
+        },
+        {
+            name: "repeated estado is not a change",
+            steps: []step{
+                {after: 0, estado: 1},
+                {after: time.Second, estado: 1},
+            },
+        },
+        {
+            name: "threshold changes within the window mark the sensor flapping",
+            steps: []step{
+                {after: 0, estado: 1},
+                {after: 3 * time.Second, estado: 0},
+                {after: 6 * time.Second, estado: 1},
+                {after: 9 * time.Second, estado: 0, suppress: true, flapping: true},
+                {after: 12 * time.Second, estado: 0, suppress: true, flapping: true},
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            d := NewFlapDetector(FlapConfig{Default: policy})
+            defer d.Stop()
+            var now time.Time
+            d.now = func() time.Time { return now }
+
+            for i, s := range tt.steps {
+                now = start.Add(s.after)
+                got := d.Observe(&domain.AlertRecord{ID: i + 1, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: s.estado}})
+                if got.Suppress != s.suppress || got.Flapping != s.flapping {
+                    t.Errorf("step %d: got %+v, want Suppress %v, Flapping %v", i, got, s.suppress, s.flapping)
+                }
+                if !got.Suppress {
+                    if !got.SettleAt.IsZero() {
+                        t.Errorf("step %d: SettleAt = %v on a notified alert", i, got.SettleAt)
+                    }
+                    continue
+                }
+                // The sensor settles once quiet for the window, the longer
+                // of the two periods.
+                if want := now.Add(policy.Window); !got.SettleAt.Equal(want) {
+                    t.Errorf("step %d: SettleAt = %v, want %v", i, got.SettleAt, want)
+                }
+            }
+        })
+    }
+}
+
+func TestFlapDetectorSettleAtRoundsUpToTheSecond(t *testing.T) {
+    d := NewFlapDetector(FlapConfig{Default: domain.FlapPolicy{Debounce: 2 * time.Second}})
+    defer d.Stop()
+    now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+    d.now = func() time.Time { return now }
+
+    d.Observe(&domain.AlertRecord{ID: 1, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}})
+    now = now.Add(1500 * time.Millisecond)
+    got := d.Observe(&domain.AlertRecord{ID: 2, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 0}})
+
+    want := time.Date(2024, 5, 1, 10, 0, 4, 0, time.UTC)
+    if !got.Suppress || !got.SettleAt.Equal(want) {
+        t.Errorf("got %+v, want suppressed with SettleAt %v", got, want)
+    }
+}
+
+func TestFlapDetectorCallsSettleHandlerForHeldAlerts(t *testing.T) {
+    d := NewFlapDetector(FlapConfig{Default: domain.FlapPolicy{Debounce: 50 * time.Millisecond}})
+    defer d.Stop()
+    settled := make(chan flapKey, 1)
+    d.SetSettleHandler(func(serial, sensor string) { settled <- flapKey{serial, sensor} })
+
+    d.Observe(&domain.AlertRecord{ID: 1, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}})
+    if got := d.Observe(&domain.AlertRecord{ID: 2, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 0}}); !got.Suppress {
+        t.Fatalf("got %+v, want the bounce suppressed", got)
+    }
+
+    // SettleAt is rounded up to the second.
+    select {
+    case key := <-settled:
+        if key != (flapKey{"ESP32-001", "KY_026"}) {
+            t.Errorf("settled %+v", key)
+        }
+    case <-time.After(3 * time.Second):
+        t.Fatal("settle handler not called")
+    }
+}
+
+func TestFlapDetectorRetriesAreNotChanges(t *testing.T) {
+    d := NewFlapDetector(FlapConfig{Default: domain.FlapPolicy{Window: time.Minute, Threshold: 4}})
+    defer d.Stop()
+    now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+    d.now = func() time.Time { return now }
+
+    // A batch of three toggles, processed again after a failure.
+    batch := []*domain.AlertRecord{
+        {ID: 1, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}},
+        {ID: 2, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 0}},
+        {ID: 3, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}},
+    }
+    var first []domain.FlapState
+    for _, record := range batch {
+        first = append(first, d.Observe(record))
+    }
+    for attempt := 0; attempt < 3; attempt++ {
+        now = now.Add(time.Second)
+        for i, record := range batch {
+            if got := d.Observe(record); got != first[i] {
+                t.Errorf("attempt %d, alert %d: got %+v, want %+v as observed first", attempt, record.ID, got, first[i])
+            }
+        }
+    }
+
+    // A new alert is the third change, still under the threshold.
+    now = now.Add(time.Second)
+    if got := d.Observe(&domain.AlertRecord{ID: 4, Alert: domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 0}}); got.Flapping {
+        t.Errorf("got %+v after three changes, want the sensor not flapping", got)
+    }
+}
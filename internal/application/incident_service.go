@@ -0,0 +1,159 @@
+package application
+
+import (
+    "errors"
+    "sync"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "time"
+)
+
+var ErrIncidentNotFound = errors.New("incidente no encontrado")
+
+// IncidentService correlates the alerts of a sensor into incidents and
+// applies the state transitions requested by users or devices.
+type IncidentService struct {
+    incidentManager ports.IncidentManager
+    mu              sync.Mutex
+    now             func() time.Time
+}
+
+func NewIncidentService(incidentManager ports.IncidentManager) *IncidentService {
+    return &IncidentService{
+        incidentManager: incidentManager,
+        now:             time.Now,
+    }
+}
+
+// deviceActor identifies transitions triggered by the device itself.
+func deviceActor(serial string) string {
+    return "device:" + serial
+}
+
+// HandleAlert opens an incident when a sensor activates, reusing the open one
+// if there is any, and resolves it when the sensor deactivates. It returns nil
+// for a deactivation that has no open incident. opened is true when a new
+// incident was created.
+//
+// A deactivation suppressed by the flap detector leaves the incident open, so
+// the toggles of a flapping sensor collapse into one incident; it is resolved
+// when the detector replays the deactivation once the sensor is quiet.
+func (s *IncidentService) HandleAlert(alert *domain.Alert, flap domain.FlapState) (incident *domain.Incident, opened bool, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    incident, err = s.incidentManager.GetOpenIncident(alert.NumeroSerie, alert.Sensor)
+    if err != nil {
+        return nil, false, err
+    }
+
+    if incident != nil && flap.Flapping && !incident.Flapping {
+        if err := s.incidentManager.MarkIncidentFlapping(incident.ID); err != nil {
+            return nil, false, err
+        }
+        incident.Flapping = true
+    }
+
+    if alert.Estado == 1 {
+        if incident != nil {
+            return incident, false, nil
+        }
+        now := s.now().UTC().Truncate(time.Second)
+        incident = &domain.Incident{
+            ESP32Serial: alert.NumeroSerie,
+            Sensor:      alert.Sensor,
+            Status:      domain.IncidentOpen,
+            OpenedAt:    now,
+            UpdatedAt:   now,
+            Flapping:    flap.Flapping,
+        }
+        if err := s.incidentManager.CreateIncident(incident, deviceActor(alert.NumeroSerie)); err != nil {
+            return nil, false, err
+        }
+        return incident, true, nil
+    }
+
+    if incident == nil {
+        return nil, false, nil
+    }
+    if flap.Suppress {
+        return incident, false, nil
+    }
+    if err := s.transition(incident, domain.IncidentResolved, deviceActor(alert.NumeroSerie)); err != nil {
+        return nil, false, err
+    }
+    return incident, false, nil
+}
+
+// ChatReceivedIncident reports whether the chat was sent a message for the
+// incident, either as a linked chat or as an escalation contact.
+func (s *IncidentService) ChatReceivedIncident(id int, chatID int64) (bool, error) {
+    messages, err := s.incidentManager.ListIncidentMessages(id)
+    if err != nil {
+        return false, err
+    }
+    for _, m := range messages {
+        if m.ChatID == chatID {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+func (s *IncidentService) GetIncident(id int) (*domain.Incident, error) {
+    return s.incidentManager.GetIncident(id)
+}
+
+func (s *IncidentService) GetOpenIncident(serial string, sensor string) (*domain.Incident, error) {
+    return s.incidentManager.GetOpenIncident(serial, sensor)
+}
+
+func (s *IncidentService) GetTransitions(id int) ([]domain.IncidentTransition, error) {
+    return s.incidentManager.ListTransitions(id)
+}
+
+func (s *IncidentService) Acknowledge(id int, actor string) (*domain.Incident, error) {
+    return s.Transition(id, domain.IncidentAcknowledged, actor)
+}
+
+func (s *IncidentService) MarkFalseAlarm(id int, actor string) (*domain.Incident, error) {
+    return s.Transition(id, domain.IncidentFalseAlarm, actor)
+}
+
+func (s *IncidentService) Resolve(id int, actor string) (*domain.Incident, error) {
+    return s.Transition(id, domain.IncidentResolved, actor)
+}
+
+// Transition moves the incident to the given state on behalf of actor.
+func (s *IncidentService) Transition(id int, next domain.IncidentStatus, actor string) (*domain.Incident, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    incident, err := s.incidentManager.GetIncident(id)
+    if err != nil {
+        return nil, err
+    }
+    if incident == nil {
+        return nil, ErrIncidentNotFound
+    }
+
+    if err := s.transition(incident, next, actor); err != nil {
+        return incident, err
+    }
+    return incident, nil
+}
+
+// transition applies the change to a copy and only updates incident once it
+// is saved, so a failed save leaves it as it is stored.
+func (s *IncidentService) transition(incident *domain.Incident, next domain.IncidentStatus, actor string) error {
+    updated := *incident
+    transition, err := updated.Transition(next, actor, s.now().UTC().Truncate(time.Second))
+    if err != nil {
+        return err
+    }
+    if err := s.incidentManager.SaveTransition(&updated, transition); err != nil {
+        return err
+    }
+    *incident = updated
+    return nil
+}
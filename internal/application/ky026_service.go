@@ -3,6 +3,7 @@ package application
 import (
     "telegramassist/internal/domain"
     "telegramassist/internal/domain/ports"
+    "time"
 )
 
 type KY026Service struct {
@@ -13,8 +14,20 @@ func (s *KY026Service) GetLastReading(serial string) (*domain.KY026Reading, erro
     return s.sensorManager.GetLastReading(serial)
 }
 
-func (s *KY026Service) ProcessKY026Alert(alert *domain.Alert) error {
-    return s.sensorManager.ProcessAlert(alert)
+// GetReadingHistory returns one page of the readings of a device in the
+// given range. Pages start at 0.
+func (s *KY026Service) GetReadingHistory(serial string, from, to time.Time, page, pageSize int) (*domain.ReadingPage, error) {
+    return s.sensorManager.ListReadings(domain.ReadingQuery{
+        Serial: serial,
+        From:   from,
+        To:     to,
+        Limit:  pageSize,
+        Offset: page * pageSize,
+    })
+}
+
+func (s *KY026Service) ListReadings(query domain.ReadingQuery) (*domain.ReadingPage, error) {
+    return s.sensorManager.ListReadings(query)
 }
 
 func NewKY026Service(sensorManager ports.KY026Manager) *KY026Service {
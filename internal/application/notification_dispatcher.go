@@ -0,0 +1,99 @@
+package application
+
+import (
+    "sync"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+)
+
+// NotificationDispatcher looks up who must hear about an alert and fans it
+// out to the registered channels concurrently, returning what happened on
+// each one.
+type NotificationDispatcher struct {
+    esp32Service       *ESP32Service
+    preferencesManager ports.NotificationPreferencesManager
+    channels           []ports.NotificationChannel
+}
+
+func NewNotificationDispatcher(
+    esp32Service *ESP32Service,
+    preferencesManager ports.NotificationPreferencesManager,
+    channels ...ports.NotificationChannel,
+) *NotificationDispatcher {
+    return &NotificationDispatcher{
+        esp32Service:       esp32Service,
+        preferencesManager: preferencesManager,
+        channels:           channels,
+    }
+}
+
+// NotifyAlert fills the recipients of the notification and delivers it. The
+// error is only about looking up recipients; channel failures are in the
+// report. A suppressed notification is reported as skipped on every channel.
+func (d *NotificationDispatcher) NotifyAlert(notification domain.AlertNotification) (domain.DeliveryReport, error) {
+    if notification.Suppressed != "" {
+        reports := make([]domain.ChannelReport, len(d.channels))
+        for i, channel := range d.channels {
+            reports[i] = domain.SkippedReport(channel.Name(), notification.Suppressed)
+        }
+        return domain.DeliveryReport{Channels: reports}, nil
+    }
+
+    serial := notification.Alert.NumeroSerie
+
+    chatIDs, err := d.esp32Service.GetLinkedChats(serial)
+    if err != nil {
+        return domain.DeliveryReport{}, err
+    }
+    owner, err := d.esp32Service.GetUserByESP32Serial(serial)
+    if err != nil {
+        return domain.DeliveryReport{}, err
+    }
+    preferences := domain.DefaultNotificationPreferences
+    if owner != nil {
+        if preferences, err = d.preferencesManager.GetNotificationPreferences(owner.ID); err != nil {
+            return domain.DeliveryReport{}, err
+        }
+    }
+    notification.ChatIDs = chatIDs
+    notification.Owner = owner
+    notification.Preferences = preferences
+
+    reports := make([]domain.ChannelReport, len(d.channels))
+    var wg sync.WaitGroup
+    for i, channel := range d.channels {
+        wg.Add(1)
+        go func(i int, channel ports.NotificationChannel) {
+            defer wg.Done()
+            reports[i] = channel.Deliver(notification)
+        }(i, channel)
+    }
+    wg.Wait()
+
+    return domain.DeliveryReport{Channels: reports}, nil
+}
+
+func (d *NotificationDispatcher) GetNotificationPreferences(userID int) (domain.NotificationPreferences, error) {
+    return d.preferencesManager.GetNotificationPreferences(userID)
+}
+
+// OutboxChannel reports the RabbitMQ notifications. They are written to the
+// outbox in the same transaction as the reading (see ESP32Service.ProcessAlert)
+// so this channel has nothing left to send; the relay publishes them.
+type OutboxChannel struct{}
+
+func (OutboxChannel) Name() string {
+    return "rabbitmq"
+}
+
+func (c OutboxChannel) Deliver(notification domain.AlertNotification) domain.ChannelReport {
+    report := domain.ChannelReport{
+        Channel:    c.Name(),
+        Recipients: notification.OutboxQueued,
+        Delivered:  notification.OutboxQueued,
+    }
+    if notification.OutboxQueued == 0 {
+        report.Reason = "device has no owner"
+    }
+    return report.Finish(domain.DeliveryQueued)
+}
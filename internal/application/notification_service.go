@@ -2,19 +2,120 @@ package application
 
 import (
     "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
     tele "gopkg.in/telebot.v3"
     "fmt"
+    "log"
+    "strconv"
+    "sync"
 )
 
+// Unique identifiers of the inline buttons attached to alert messages
+const (
+    CallbackAcknowledge = "inc_ack"
+    CallbackFalseAlarm  = "inc_false"
+    CallbackCallContact = "inc_call"
+)
+
+// NotificationService sends the alert messages of the bot. Every call to the
+// Telegram API goes through a rate-limited send queue so a building-wide
+// alarm does not hit Telegram's flood limits.
 type NotificationService struct {
-    bot *tele.Bot
+    bot             *tele.Bot
+    incidentManager ports.IncidentManager
+    queue           *telegramQueue
+}
+
+func NewNotificationService(bot *tele.Bot, incidentManager ports.IncidentManager, queueConfig TelegramQueueConfig) *NotificationService {
+    return &NotificationService{
+        bot:             bot,
+        incidentManager: incidentManager,
+        queue:           newTelegramQueue(queueConfig),
+    }
+}
+
+// Stop waits for the queued messages to be sent.
+func (s *NotificationService) Stop() {
+    s.queue.close()
+}
+
+func (s *NotificationService) QueueStats() QueueStats {
+    return s.queue.stats()
+}
+
+// Health implements the health check used by the /health endpoint; the queue
+// is unhealthy while it is full.
+func (s *NotificationService) Health() (string, bool) {
+    stats := s.queue.stats()
+    return stats.String(), stats.Depth < stats.Capacity
+}
+
+// send queues a message for the chat and waits until Telegram accepts it.
+func (s *NotificationService) send(chatID int64, what interface{}, opts ...interface{}) (*tele.Message, error) {
+    var msg *tele.Message
+    err := s.queue.do(chatID, func() error {
+        var err error
+        msg, err = s.bot.Send(&tele.Chat{ID: chatID}, what, opts...)
+        return err
+    })
+    return msg, err
 }
 
-func NewNotificationService(bot *tele.Bot) *NotificationService {
-    return &NotificationService{bot: bot}
+func (s *NotificationService) edit(stored tele.StoredMessage, what interface{}, opts ...interface{}) error {
+    return s.queue.do(stored.ChatID, func() error {
+        _, err := s.bot.Edit(stored, what, opts...)
+        return err
+    })
 }
 
-func (s *NotificationService) SendTelegramNotification(chatID int64, alert *domain.Alert) error {
+func (s *NotificationService) Name() string {
+    return "telegram"
+}
+
+// Deliver sends the alert to every linked chat, unless the owner of the
+// device turned off EnableDeviceChats, which silences all of them. When the
+// alert closed the incident, the messages sent when it opened are refreshed
+// so their buttons go away.
+func (s *NotificationService) Deliver(notification domain.AlertNotification) domain.ChannelReport {
+    report := domain.ChannelReport{Channel: s.Name()}
+    incident := notification.Incident
+    if incident != nil && incident.Status.Closed() {
+        if err := s.UpdateIncidentMessages(incident, "el dispositivo"); err != nil {
+            report.Errors = append(report.Errors, err.Error())
+        }
+    }
+
+    if notification.Owner != nil && !notification.Preferences.EnableDeviceChats {
+        report.Reason = "disabled in preferences"
+        return report.Finish(domain.DeliverySent)
+    }
+    // The queue paces the sends; queueing them all at once lets different
+    // chats be served in parallel.
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    for _, chatID := range notification.ChatIDs {
+        wg.Add(1)
+        go func(chatID int64) {
+            defer wg.Done()
+            err := s.SendTelegramNotification(chatID, notification)
+            mu.Lock()
+            report.Record(err)
+            mu.Unlock()
+        }(chatID)
+    }
+    wg.Wait()
+    return report.Finish(domain.DeliverySent)
+}
+
+var incidentStatusText = map[domain.IncidentStatus]string{
+    domain.IncidentOpen:         "Abierto",
+    domain.IncidentAcknowledged: "Atendido",
+    domain.IncidentResolved:     "Resuelto",
+    domain.IncidentFalseAlarm:   "Falsa alarma",
+}
+
+func (s *NotificationService) SendTelegramNotification(chatID int64, notification domain.AlertNotification) error {
+    alert, incident := notification.Alert, notification.Incident
     estadoTexto := "Desactivado"
     if alert.Estado == 1 {
         estadoTexto = "Activado"
@@ -22,7 +123,103 @@ func (s *NotificationService) SendTelegramNotification(chatID int64, alert *doma
 
     mensaje := fmt.Sprintf("🚨 *ALERTA DE SENSOR* 🚨\n\nSensor: %s\nEstado: %s\nActivación: %s\nDesactivación: %s",
         alert.Sensor, estadoTexto, alert.FechaActivacion, alert.FechaDesactivacion)
+    if notification.Coalesced > 1 {
+        mensaje += fmt.Sprintf("\nLecturas recibidas en lote: %d (se muestra la última no suprimida)", notification.Coalesced)
+    }
+    if incident == nil {
+        _, err := s.send(chatID, mensaje)
+        return err
+    }
 
-    _, err := s.bot.Send(&tele.Chat{ID: chatID}, mensaje)
-    return err
-}
\ No newline at end of file
+    var opts []interface{}
+    if !incident.Status.Closed() {
+        opts = append(opts, incidentMarkup(incident))
+    }
+
+    msg, err := s.send(chatID, mensaje+incidentStatusLine(incident, ""), opts...)
+    if err != nil {
+        return err
+    }
+
+    return s.incidentManager.SaveIncidentMessage(domain.IncidentMessage{
+        IncidentID: incident.ID,
+        ChatID:     chatID,
+        MessageID:  msg.ID,
+        Text:       mensaje,
+    })
+}
+
+// SendIncidentReminder reminds a chat that an incident is still waiting for
+// someone to acknowledge it.
+func (s *NotificationService) SendIncidentReminder(chatID int64, incident *domain.Incident) error {
+    mensaje := fmt.Sprintf("⚠️ *RECORDATORIO* ⚠️\n\nNadie ha atendido la alerta del sensor %s (%s) abierta a las %s.",
+        incident.Sensor, incident.ESP32Serial, incident.OpenedAt.Local().Format("15:04"))
+
+    msg, err := s.send(chatID, mensaje+incidentStatusLine(incident, ""), incidentMarkup(incident))
+    if err != nil {
+        return err
+    }
+
+    return s.incidentManager.SaveIncidentMessage(domain.IncidentMessage{
+        IncidentID: incident.ID,
+        ChatID:     chatID,
+        MessageID:  msg.ID,
+        Text:       mensaje,
+    })
+}
+
+// UpdateIncidentMessages edits every message sent for the incident so all
+// linked chats see its current state and who changed it. The buttons are
+// removed once the incident is closed.
+func (s *NotificationService) UpdateIncidentMessages(incident *domain.Incident, by string) error {
+    messages, err := s.incidentManager.ListIncidentMessages(incident.ID)
+    if err != nil {
+        return err
+    }
+
+    // Editing without a reply markup removes the inline keyboard.
+    var opts []interface{}
+    if !incident.Status.Closed() {
+        opts = append(opts, incidentMarkup(incident))
+    }
+
+    var lastErr error
+    for _, m := range messages {
+        stored := tele.StoredMessage{MessageID: strconv.Itoa(m.MessageID), ChatID: m.ChatID}
+        if err := s.edit(stored, m.Text+incidentStatusLine(incident, by), opts...); err != nil {
+            log.Printf("Error editing message %d in chat %d: %v", m.MessageID, m.ChatID, err)
+            lastErr = err
+        }
+    }
+    return lastErr
+}
+
+func incidentStatusLine(incident *domain.Incident, by string) string {
+    line := fmt.Sprintf("\n\nIncidente #%d: %s", incident.ID, incidentStatusText[incident.Status])
+    if by != "" {
+        line += fmt.Sprintf(" por %s (%s)", by, incident.UpdatedAt.Local().Format("15:04"))
+    }
+    if incident.Flapping {
+        line += "\n⚠️ El sensor cambia de estado repetidamente; sus alertas se agrupan en este incidente"
+    }
+    return line
+}
+
+func incidentMarkup(incident *domain.Incident) *tele.ReplyMarkup {
+    markup := &tele.ReplyMarkup{}
+    id := strconv.Itoa(incident.ID)
+
+    var rows []tele.Row
+    if incident.Status == domain.IncidentOpen {
+        rows = append(rows, markup.Row(
+            markup.Data("✅ Atender", CallbackAcknowledge, id),
+            markup.Data("❌ Falsa alarma", CallbackFalseAlarm, id),
+        ))
+    } else {
+        rows = append(rows, markup.Row(markup.Data("❌ Falsa alarma", CallbackFalseAlarm, id)))
+    }
+    rows = append(rows, markup.Row(markup.Data("📞 Llamar a contacto de emergencia", CallbackCallContact, id)))
+
+    markup.Inline(rows...)
+    return markup
+}
@@ -0,0 +1,138 @@
+package application
+
+import (
+    "errors"
+    "log"
+    "sync"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "time"
+)
+
+const (
+    outboxBatchSize  = 50
+    outboxMinBackoff = 5 * time.Second
+    outboxMaxBackoff = 10 * time.Minute
+)
+
+// retryable is implemented by publisher errors that know whether publishing
+// again may succeed, such as *rabbitmq.PublishError.
+type retryable interface {
+    error
+    Temporary() bool
+}
+
+// OutboxRelay publishes the messages written to the outbox by ProcessAlert.
+// A message is marked as sent only after the broker confirms it, so delivery
+// is at least once; failed messages are retried with exponential backoff
+// and marked as failed after maxAttempts.
+type OutboxRelay struct {
+    outboxManager ports.OutboxManager
+    publisher     ports.MessagePublisher
+    interval      time.Duration
+    maxAttempts   int
+
+    done     chan struct{}
+    stopOnce sync.Once
+    wg       sync.WaitGroup
+}
+
+func NewOutboxRelay(outboxManager ports.OutboxManager, publisher ports.MessagePublisher, interval time.Duration, maxAttempts int) *OutboxRelay {
+    return &OutboxRelay{
+        outboxManager: outboxManager,
+        publisher:     publisher,
+        interval:      interval,
+        maxAttempts:   maxAttempts,
+        done:          make(chan struct{}),
+    }
+}
+
+// Start polls the outbox in the background until Stop is called.
+func (r *OutboxRelay) Start() {
+    r.wg.Add(1)
+    go func() {
+        defer r.wg.Done()
+
+        ticker := time.NewTicker(r.interval)
+        defer ticker.Stop()
+        for {
+            r.drain()
+            select {
+            case <-r.done:
+                return
+            case <-ticker.C:
+            }
+        }
+    }()
+}
+
+// Stop waits for the batch being published to finish.
+func (r *OutboxRelay) Stop() {
+    r.stopOnce.Do(func() { close(r.done) })
+    r.wg.Wait()
+}
+
+// drain publishes due messages until the outbox has no full batch left.
+func (r *OutboxRelay) drain() {
+    for {
+        // The lease must outlast publishing the whole batch, or another
+        // relay could claim the same messages again.
+        messages, err := r.outboxManager.ClaimPendingOutbox(outboxBatchSize, 2*time.Minute)
+        if err != nil {
+            log.Printf("Error reading notification outbox: %v", err)
+            return
+        }
+        for _, message := range messages {
+            r.publish(message)
+        }
+        if len(messages) < outboxBatchSize {
+            return
+        }
+        select {
+        case <-r.done:
+            return
+        default:
+        }
+    }
+}
+
+func (r *OutboxRelay) publish(message domain.OutboxMessage) {
+    err := r.publisher.PublishMessage(message.RoutingKey, message.Payload)
+    if err == nil {
+        if err := r.outboxManager.MarkOutboxSent(message.ID); err != nil {
+            log.Printf("Error marking outbox message %d as sent: %v", message.ID, err)
+        }
+        return
+    }
+
+    attempts := message.Attempts + 1
+    log.Printf("Error publishing outbox message %d (attempt %d): %v", message.ID, attempts, err)
+    if attempts >= r.maxAttempts {
+        if err := r.outboxManager.MarkOutboxFailed(message.ID, attempts, err.Error()); err != nil {
+            log.Printf("Error marking outbox message %d as failed: %v", message.ID, err)
+        }
+        return
+    }
+
+    next := time.Now().UTC().Add(outboxBackoff(attempts))
+    var re retryable
+    if errors.As(err, &re) && !re.Temporary() {
+        // Retrying soon will not help (e.g. the message is unroutable); retry
+        // at the slowest pace in case it is fixed before the last attempt.
+        next = time.Now().UTC().Add(outboxMaxBackoff)
+    }
+    if err := r.outboxManager.RescheduleOutbox(message.ID, attempts, next, err.Error()); err != nil {
+        log.Printf("Error rescheduling outbox message %d: %v", message.ID, err)
+    }
+}
+
+func outboxBackoff(attempts int) time.Duration {
+    backoff := outboxMinBackoff
+    for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+        backoff *= 2
+    }
+    if backoff > outboxMaxBackoff {
+        backoff = outboxMaxBackoff
+    }
+    return backoff
+}
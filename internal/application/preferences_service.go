@@ -0,0 +1,47 @@
+package application
+
+import (
+    "errors"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+)
+
+var ErrUserNotFound = errors.New("usuario no encontrado")
+
+// PreferencesService reads and updates the notification channels of users.
+type PreferencesService struct {
+    userManager        ports.UserManager
+    preferencesManager ports.NotificationPreferencesManager
+}
+
+func NewPreferencesService(userManager ports.UserManager, preferencesManager ports.NotificationPreferencesManager) *PreferencesService {
+    return &PreferencesService{
+        userManager:        userManager,
+        preferencesManager: preferencesManager,
+    }
+}
+
+func (s *PreferencesService) Get(userID int) (domain.NotificationPreferences, error) {
+    if err := s.ensureUser(userID); err != nil {
+        return domain.NotificationPreferences{}, err
+    }
+    return s.preferencesManager.GetNotificationPreferences(userID)
+}
+
+func (s *PreferencesService) Save(userID int, preferences domain.NotificationPreferences) error {
+    if err := s.ensureUser(userID); err != nil {
+        return err
+    }
+    return s.preferencesManager.SaveNotificationPreferences(userID, preferences)
+}
+
+func (s *PreferencesService) ensureUser(userID int) error {
+    user, err := s.userManager.GetUser(userID)
+    if err != nil {
+        return err
+    }
+    if user == nil {
+        return ErrUserNotFound
+    }
+    return nil
+}
@@ -0,0 +1,46 @@
+package application
+
+import (
+    "fmt"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+)
+
+// SMSNotifier texts the owner of a device when a high-severity incident is
+// opened. SMS cost money, so deactivations, lower severities and repeated
+// alerts of an incident that is already open are never texted.
+type SMSNotifier struct {
+    sender ports.SMSSender
+}
+
+func NewSMSNotifier(sender ports.SMSSender) *SMSNotifier {
+    return &SMSNotifier{sender: sender}
+}
+
+func (n *SMSNotifier) Name() string {
+    return "sms"
+}
+
+func (n *SMSNotifier) Deliver(notification domain.AlertNotification) domain.ChannelReport {
+    alert, owner := notification.Alert, notification.Owner
+    switch {
+    case !notification.IncidentOpened:
+        return domain.SkippedReport(n.Name(), "no new incident")
+    case domain.AlertSeverity(alert) != domain.SeverityHigh:
+        return domain.SkippedReport(n.Name(), "severity below high")
+    case owner == nil:
+        return domain.SkippedReport(n.Name(), "device has no owner")
+    case !notification.Preferences.EnableSMS:
+        return domain.SkippedReport(n.Name(), "disabled in preferences")
+    case owner.Phone == "":
+        return domain.SkippedReport(n.Name(), "owner has no phone")
+    }
+
+    // Kept under 160 GSM characters so it fits in a single SMS.
+    text := fmt.Sprintf("ALERTA: sensor %s activado en %s (%s). Incidente #%d. Revisa Telegram.",
+        alert.Sensor, alert.NumeroSerie, alert.FechaActivacion, notification.Incident.ID)
+
+    report := domain.ChannelReport{Channel: n.Name()}
+    report.Record(n.sender.SendSMS(owner.Phone, text))
+    return report.Finish(domain.DeliverySent)
+}
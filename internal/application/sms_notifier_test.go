@@ -0,0 +1,135 @@
+package application
+
+import (
+    "errors"
+    "strings"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/infrastructure/sms"
+    "testing"
+)
+
+func TestSMSNotifierDeliver(t *testing.T) {
+    owner := &domain.User{Username: "ana", Phone: "+5219611234567"}
+    flame := &domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1, FechaActivacion: "10:00"}
+    smsOn := domain.NotificationPreferences{EnableSMS: true}
+
+    notification := func(edit func(n *domain.AlertNotification)) domain.AlertNotification {
+        n := domain.AlertNotification{
+            Alert:          flame,
+            Incident:       &domain.Incident{ID: 7, Status: domain.IncidentOpen},
+            IncidentOpened: true,
+            Owner:          owner,
+            Preferences:    smsOn,
+        }
+        if edit != nil {
+            edit(&n)
+        }
+        return n
+    }
+
+    tests := []struct {
+        name         string
+        notification domain.AlertNotification
+        sendErr      error
+        status       domain.DeliveryStatus
+        reason       string
+        sent         bool
+    }{
+        {
+            name:         "high severity incident opened",
+            notification: notification(nil),
+            status:       domain.DeliverySent,
+            sent:         true,
+        },
+        {
+            name: "low severity",
+            notification: notification(func(n *domain.AlertNotification) {
+                n.Alert = &domain.Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 0}
+            }),
+            status: domain.DeliverySkipped,
+            reason: "severity below high",
+        },
+        {
+            name: "medium severity",
+            notification: notification(func(n *domain.AlertNotification) {
+                n.Alert = &domain.Alert{NumeroSerie: "ESP32-001", Sensor: "MQ_2", Estado: 1}
+            }),
+            status: domain.DeliverySkipped,
+            reason: "severity below high",
+        },
+        {
+            name:         "incident already open",
+            notification: notification(func(n *domain.AlertNotification) { n.IncidentOpened = false }),
+            status:       domain.DeliverySkipped,
+            reason:       "no new incident",
+        },
+        {
+            name:         "no owner",
+            notification: notification(func(n *domain.AlertNotification) { n.Owner = nil }),
+            status:       domain.DeliverySkipped,
+            reason:       "device has no owner",
+        },
+        {
+            name: "preferences off",
+            notification: notification(func(n *domain.AlertNotification) {
+                n.Preferences = domain.NotificationPreferences{EnableDeviceChats: true, EnableEmail: true}
+            }),
+            status: domain.DeliverySkipped,
+            reason: "disabled in preferences",
+        },
+        {
+            name: "empty phone",
+            notification: notification(func(n *domain.AlertNotification) {
+                n.Owner = &domain.User{Username: "ana"}
+            }),
+            status: domain.DeliverySkipped,
+            reason: "owner has no phone",
+        },
+        {
+            name:         "send fails",
+            notification: notification(nil),
+            sendErr:      errors.New("gateway down"),
+            status:       domain.DeliveryFailed,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            sender := sms.NewFakeSender()
+            sender.Err = tt.sendErr
+
+            report := NewSMSNotifier(sender).Deliver(tt.notification)
+            if report.Channel != "sms" {
+                t.Errorf("Channel = %q, want sms", report.Channel)
+            }
+            if report.Status != tt.status {
+                t.Errorf("Status = %q, want %q", report.Status, tt.status)
+            }
+            if report.Reason != tt.reason {
+                t.Errorf("Reason = %q, want %q", report.Reason, tt.reason)
+            }
+
+            sent := sender.Sent()
+            if !tt.sent {
+                if len(sent) != 0 {
+                    t.Errorf("sent %+v, want nothing", sent)
+                }
+                return
+            }
+            if len(sent) != 1 {
+                t.Fatalf("sent %d SMS, want 1", len(sent))
+            }
+            if sent[0].To != owner.Phone {
+                t.Errorf("To = %q, want %q", sent[0].To, owner.Phone)
+            }
+            for _, want := range []string{"KY_026", "ESP32-001", "Incidente #7"} {
+                if !strings.Contains(sent[0].Text, want) {
+                    t.Errorf("text %q does not contain %q", sent[0].Text, want)
+                }
+            }
+            if len(sent[0].Text) > 160 {
+                t.Errorf("text is %d characters long, want at most 160", len(sent[0].Text))
+            }
+        })
+    }
+}
@@ -0,0 +1,450 @@
+package application
+
+import (
+    "container/heap"
+    "errors"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    tele "gopkg.in/telebot.v3"
+)
+
+var (
+    ErrSendQueueFull   = errors.New("la cola de envío de Telegram está llena")
+    ErrSendQueueClosed = errors.New("la cola de envío de Telegram está cerrada")
+)
+
+const (
+    sendQueueTimeout   = 5 * time.Second
+    maxFloodRetries    = 3
+    latencySamples     = 1024
+    chatBucketIdleTime = 10 * time.Minute
+
+    // Floods in this many chats within globalFloodWindow are taken as the
+    // global limit being hit, so every chat is paused.
+    globalFloodChats  = 3
+    globalFloodWindow = 5 * time.Second
+)
+
+// TelegramQueueConfig sizes the Telegram send queue. Telegram allows about
+// 30 messages per second overall and 20 per minute in a group.
+type TelegramQueueConfig struct {
+    Workers         int
+    Capacity        int
+    GlobalPerSecond int
+    ChatPerMinute   int
+}
+
+// tokenBucket is a rate limiter refilled continuously at rate tokens per
+// second up to burst. A flood error pauses it until Telegram allows sending
+// again.
+type tokenBucket struct {
+    rate     float64
+    burst    float64
+    tokens   float64
+    last     time.Time
+    paused   time.Time
+    lastUsed time.Time
+}
+
+func newTokenBucket(rate float64, burst float64, now time.Time) *tokenBucket {
+    return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now, lastUsed: now}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+    b.lastUsed = now
+    if now.Before(b.paused) {
+        now = b.paused
+    }
+    if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+        b.tokens += elapsed * b.rate
+        if b.tokens > b.burst {
+            b.tokens = b.burst
+        }
+        b.last = now
+    }
+    b.tokens--
+
+    wait := time.Until(now)
+    if b.tokens < 0 {
+        wait += time.Duration(-b.tokens / b.rate * float64(time.Second))
+    }
+    if wait < 0 {
+        wait = 0
+    }
+    return wait
+}
+
+func (b *tokenBucket) pause(until time.Time) {
+    if until.After(b.paused) {
+        b.paused = until
+    }
+}
+
+// sendJob is one call to the Telegram API for a chat.
+type sendJob struct {
+    chatID   int64
+    send     func() error
+    queuedAt time.Time
+    result   chan error
+
+    readyAt  time.Time
+    attempts int
+}
+
+// delayHeap orders the jobs waiting for their tokens by the time they may be
+// sent.
+type delayHeap []*sendJob
+
+func (h delayHeap) Len() int            { return len(h) }
+func (h delayHeap) Less(i, j int) bool  { return h[i].readyAt.Before(h[j].readyAt) }
+func (h delayHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *delayHeap) Push(x interface{}) { *h = append(*h, x.(*sendJob)) }
+func (h *delayHeap) Pop() interface{} {
+    old := *h
+    job := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return job
+}
+
+// sendResult is a job handed back by a worker; retry asks for it to be
+// scheduled again after a flood error.
+type sendResult struct {
+    job   *sendJob
+    retry bool
+}
+
+// telegramQueue serializes the calls to the Telegram API through a bounded
+// queue. A scheduler takes a token of the global bucket and of the chat's
+// bucket for each call and keeps it in a delay heap until then, handing the
+// calls that are due to a pool of workers; workers never wait for tokens, so
+// throttled chats do not hold up the others. Flood errors (429) are retried
+// after the retry_after Telegram asks for.
+type telegramQueue struct {
+    jobs          chan sendJob
+    ready         chan *sendJob
+    results       chan sendResult
+    globalRate    float64
+    chatPerSecond float64
+
+    mu      sync.Mutex
+    global  *tokenBucket
+    chats   map[int64]*tokenBucket
+    floods  map[int64]time.Time // last flood error of each chat
+    delayed int
+    metrics queueMetrics
+
+    // closeMu is held for reading while enqueueing so close never closes
+    // jobs under a sender.
+    closeMu sync.RWMutex
+    closed  bool
+
+    wg sync.WaitGroup
+}
+
+func newTelegramQueue(config TelegramQueueConfig) *telegramQueue {
+    if config.Workers <= 0 {
+        config.Workers = 4
+    }
+    if config.Capacity <= 0 {
+        config.Capacity = 1000
+    }
+    if config.GlobalPerSecond <= 0 {
+        config.GlobalPerSecond = 25
+    }
+    if config.ChatPerMinute <= 0 {
+        config.ChatPerMinute = 20
+    }
+
+    now := time.Now()
+    q := &telegramQueue{
+        jobs:          make(chan sendJob, config.Capacity),
+        ready:         make(chan *sendJob),
+        results:       make(chan sendResult, config.Workers),
+        globalRate:    float64(config.GlobalPerSecond),
+        chatPerSecond: float64(config.ChatPerMinute) / 60,
+        global:        newTokenBucket(float64(config.GlobalPerSecond), float64(config.GlobalPerSecond), now),
+        chats:         make(map[int64]*tokenBucket),
+        floods:        make(map[int64]time.Time),
+    }
+    q.wg.Add(1)
+    go q.scheduler()
+    for i := 0; i < config.Workers; i++ {
+        q.wg.Add(1)
+        go q.worker()
+    }
+    return q
+}
+
+// enqueue queues send and returns the channel its final error is delivered
+// on. It waits up to sendQueueTimeout for room in the queue.
+func (q *telegramQueue) enqueue(chatID int64, send func() error) <-chan error {
+    result := make(chan error, 1)
+
+    q.closeMu.RLock()
+    defer q.closeMu.RUnlock()
+    if q.closed {
+        result <- ErrSendQueueClosed
+        return result
+    }
+
+    job := sendJob{chatID: chatID, send: send, queuedAt: time.Now(), result: result}
+    select {
+    case q.jobs <- job:
+    case <-time.After(sendQueueTimeout):
+        q.mu.Lock()
+        q.metrics.rejected++
+        q.mu.Unlock()
+        result <- ErrSendQueueFull
+    }
+    return result
+}
+
+// do queues send and waits for its result.
+func (q *telegramQueue) do(chatID int64, send func() error) error {
+    return <-q.enqueue(chatID, send)
+}
+
+// close stops accepting messages and waits for the queued ones to be sent.
+func (q *telegramQueue) close() {
+    q.closeMu.Lock()
+    if q.closed {
+        q.closeMu.Unlock()
+        return
+    }
+    q.closed = true
+    close(q.jobs)
+    q.closeMu.Unlock()
+
+    q.wg.Wait()
+}
+
+// scheduler moves the queued jobs into the delay heap and hands them to the
+// workers once they are due. It stops when the queue is closed and every job
+// has been sent.
+func (q *telegramQueue) scheduler() {
+    defer q.wg.Done()
+    defer close(q.ready)
+
+    var delayed delayHeap
+    jobs := q.jobs
+    running := 0
+    timer := time.NewTimer(time.Hour)
+    timer.Stop()
+
+    schedule := func(job *sendJob) {
+        job.readyAt = time.Now().Add(q.reserve(job.chatID))
+        heap.Push(&delayed, job)
+    }
+
+    for jobs != nil || len(delayed) > 0 || running > 0 {
+        q.mu.Lock()
+        q.delayed = len(delayed)
+        q.mu.Unlock()
+
+        var ready chan *sendJob
+        var next *sendJob
+        var wake <-chan time.Time
+        if len(delayed) > 0 {
+            next = delayed[0]
+            if wait := time.Until(next.readyAt); wait > 0 {
+                timer.Reset(wait)
+                wake = timer.C
+            } else if until, paused := q.pausedUntil(next); paused {
+                // A flood paused the chat after its token was taken: the job
+                // keeps its token and waits for the pause to end.
+                next.readyAt = until
+                heap.Fix(&delayed, 0)
+                continue
+            } else {
+                ready = q.ready
+            }
+        }
+
+        select {
+        case job, ok := <-jobs:
+            if !ok {
+                jobs = nil
+                break
+            }
+            schedule(&job)
+        case ready <- next:
+            heap.Pop(&delayed)
+            running++
+        case result := <-q.results:
+            running--
+            if result.retry {
+                schedule(result.job)
+            }
+        case <-wake:
+        }
+
+        if wake != nil && !timer.Stop() {
+            select {
+            case <-timer.C:
+            default:
+            }
+        }
+    }
+}
+
+func (q *telegramQueue) worker() {
+    defer q.wg.Done()
+    for job := range q.ready {
+        err := job.send()
+        var flood tele.FloodError
+        if errors.As(err, &flood) && job.attempts < maxFloodRetries {
+            job.attempts++
+            q.flood(job.chatID, time.Duration(flood.RetryAfter)*time.Second)
+            q.results <- sendResult{job: job, retry: true}
+            continue
+        }
+
+        q.mu.Lock()
+        q.metrics.record(time.Since(job.queuedAt), err)
+        q.mu.Unlock()
+        job.result <- err
+        q.results <- sendResult{job: job}
+    }
+}
+
+// flood pauses the chat after a flood error. Telegram does not say whether
+// the error is about the chat or the global limit, so when several chats get
+// one at about the same time the global bucket is paused too.
+func (q *telegramQueue) flood(chatID int64, retryAfter time.Duration) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    now := time.Now()
+    until := now.Add(retryAfter)
+    q.metrics.floodWaits++
+    q.chatBucket(chatID, now).pause(until)
+
+    q.floods[chatID] = now
+    for id, at := range q.floods {
+        if now.Sub(at) > globalFloodWindow {
+            delete(q.floods, id)
+        }
+    }
+    if len(q.floods) >= globalFloodChats {
+        q.global.pause(until)
+    }
+}
+
+// pausedUntil returns the end of the pause of the chat or the global bucket
+// if it goes past the time the job was scheduled for.
+func (q *telegramQueue) pausedUntil(job *sendJob) (time.Time, bool) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    paused := q.global.paused
+    if bucket, ok := q.chats[job.chatID]; ok && bucket.paused.After(paused) {
+        paused = bucket.paused
+    }
+    return paused, paused.After(job.readyAt) && paused.After(time.Now())
+}
+
+// reserve takes a token from the chat and the global buckets and returns
+// how long to wait before sending.
+func (q *telegramQueue) reserve(chatID int64) time.Duration {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    now := time.Now()
+    wait := q.chatBucket(chatID, now).reserve(now)
+    if global := q.global.reserve(now.Add(wait)); global > wait {
+        wait = global
+    }
+    return wait
+}
+
+// chatBucket returns the bucket of a chat, dropping the buckets of chats
+// that have been idle for a while so the map stays small. q.mu must be held.
+func (q *telegramQueue) chatBucket(chatID int64, now time.Time) *tokenBucket {
+    bucket, ok := q.chats[chatID]
+    if !ok {
+        if len(q.chats) >= 1000 {
+            for id, b := range q.chats {
+                if now.Sub(b.lastUsed) > chatBucketIdleTime {
+                    delete(q.chats, id)
+                }
+            }
+        }
+        bucket = newTokenBucket(q.chatPerSecond, 3, now)
+        q.chats[chatID] = bucket
+    }
+    return bucket
+}
+
+// QueueStats summarizes the send queue. Latencies go from enqueueing a
+// message to Telegram answering, over the last latencySamples sends.
+type QueueStats struct {
+    Depth      int
+    Capacity   int
+    Sent       int
+    Failed     int
+    Rejected   int
+    FloodWaits int
+    P50        time.Duration
+    P95        time.Duration
+    P99        time.Duration
+    Max        time.Duration
+}
+
+func (q *telegramQueue) stats() QueueStats {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    stats := q.metrics.snapshot()
+    stats.Depth = len(q.jobs) + q.delayed
+    stats.Capacity = cap(q.jobs)
+    return stats
+}
+
+// queueMetrics keeps counters and a fixed-size ring of latencies, so memory
+// does not grow with traffic.
+type queueMetrics struct {
+    sent, failed, rejected, floodWaits int
+    latencies                          [latencySamples]time.Duration
+    next, count                        int
+}
+
+func (m *queueMetrics) record(latency time.Duration, err error) {
+    if err != nil {
+        m.failed++
+    } else {
+        m.sent++
+    }
+    m.latencies[m.next] = latency
+    m.next = (m.next + 1) % latencySamples
+    if m.count < latencySamples {
+        m.count++
+    }
+}
+
+func (m *queueMetrics) snapshot() QueueStats {
+    stats := QueueStats{Sent: m.sent, Failed: m.failed, Rejected: m.rejected, FloodWaits: m.floodWaits}
+    if m.count == 0 {
+        return stats
+    }
+
+    sorted := make([]time.Duration, m.count)
+    copy(sorted, m.latencies[:m.count])
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+    percentile := func(p int) time.Duration {
+        return sorted[(len(sorted)-1)*p/100]
+    }
+    stats.P50, stats.P95, stats.P99 = percentile(50), percentile(95), percentile(99)
+    stats.Max = sorted[len(sorted)-1]
+    return stats
+}
+
+func (s QueueStats) String() string {
+    return fmt.Sprintf("depth=%d/%d sent=%d failed=%d rejected=%d flood_waits=%d p50=%v p95=%v p99=%v max=%v",
+        s.Depth, s.Capacity, s.Sent, s.Failed, s.Rejected, s.FloodWaits,
+        s.P50.Round(time.Millisecond), s.P95.Round(time.Millisecond), s.P99.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+}
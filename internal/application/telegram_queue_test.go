@@ -0,0 +1,134 @@
+package application
+
+import (
+    "sync"
+    "testing"
+    "time"
+
+    tele "gopkg.in/telebot.v3"
+)
+
+func TestTelegramQueueThrottledChatDoesNotBlockOthers(t *testing.T) {
+    q := newTelegramQueue(TelegramQueueConfig{Workers: 1, ChatPerMinute: 1})
+    defer func() {
+        // The throttled chat would keep close waiting for a minute.
+        go q.close()
+    }()
+
+    // The chat bucket allows a burst of 3; the rest of the chat's messages
+    // wait about a minute each.
+    for i := 0; i < 5; i++ {
+        q.enqueue(1, func() error { return nil })
+    }
+
+    start := time.Now()
+    if err := q.do(2, func() error { return nil }); err != nil {
+        t.Fatalf("do() error = %v", err)
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("another chat waited %v behind a throttled one", elapsed)
+    }
+    deadline := time.Now().Add(time.Second)
+    for q.stats().Depth != 2 {
+        if time.Now().After(deadline) {
+            t.Fatalf("Depth = %d, want the 2 throttled messages", q.stats().Depth)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestTelegramQueueRetriesFloodErrors(t *testing.T) {
+    q := newTelegramQueue(TelegramQueueConfig{})
+    defer q.close()
+
+    calls := 0
+    start := time.Now()
+    err := q.do(1, func() error {
+        calls++
+        if calls == 1 {
+            return tele.FloodError{RetryAfter: 1}
+        }
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("do() error = %v", err)
+    }
+    if calls != 2 {
+        t.Errorf("send called %d times, want 2", calls)
+    }
+    if elapsed := time.Since(start); elapsed < time.Second {
+        t.Errorf("retried after %v, want at least retry_after", elapsed)
+    }
+    if stats := q.stats(); stats.FloodWaits != 1 || stats.Sent != 1 {
+        t.Errorf("stats = %+v, want 1 flood wait and 1 sent", stats)
+    }
+}
+
+func TestTelegramQueuePausesGlobalBucketOnFloodsInSeveralChats(t *testing.T) {
+    q := newTelegramQueue(TelegramQueueConfig{})
+    defer func() { go q.close() }()
+
+    for chatID := int64(1); chatID < globalFloodChats; chatID++ {
+        q.flood(chatID, time.Minute)
+    }
+    q.mu.Lock()
+    paused := q.global.paused
+    q.mu.Unlock()
+    if paused.After(time.Now()) {
+        t.Fatalf("global bucket paused after floods in %d chats", globalFloodChats-1)
+    }
+
+    q.flood(globalFloodChats, time.Minute)
+    q.mu.Lock()
+    paused = q.global.paused
+    q.mu.Unlock()
+    if !paused.After(time.Now().Add(50 * time.Second)) {
+        t.Errorf("global bucket not paused after floods in %d chats", globalFloodChats)
+    }
+
+    // A chat that never got a flood now waits for the global pause.
+    var mu sync.Mutex
+    sent := false
+    q.enqueue(99, func() error {
+        mu.Lock()
+        sent = true
+        mu.Unlock()
+        return nil
+    })
+    time.Sleep(100 * time.Millisecond)
+    mu.Lock()
+    defer mu.Unlock()
+    if sent {
+        t.Error("message sent while the global bucket was paused")
+    }
+}
+
+func TestTelegramQueuePauseKeepsTheReservedToken(t *testing.T) {
+    // One message per second in the chat, after a burst of 3.
+    q := newTelegramQueue(TelegramQueueConfig{Workers: 1, ChatPerMinute: 60})
+    defer q.close()
+
+    start := time.Now()
+    for i := 0; i < 3; i++ {
+        q.enqueue(1, func() error { return nil })
+    }
+    last := q.enqueue(1, func() error { return nil })
+    deadline := time.Now().Add(time.Second)
+    for q.stats().Sent != 3 {
+        if time.Now().After(deadline) {
+            t.Fatalf("Sent = %d, want the burst of 3 sent", q.stats().Sent)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    // The last message got its token for about 1s; a flood pauses the chat
+    // past that, so it waits for the pause but not for another token.
+    pauseEnd := start.Add(1500 * time.Millisecond)
+    q.flood(1, time.Until(pauseEnd))
+    if err := <-last; err != nil {
+        t.Fatalf("send error = %v", err)
+    }
+    if sent := time.Now(); sent.Before(pauseEnd) || sent.After(pauseEnd.Add(300*time.Millisecond)) {
+        t.Errorf("sent %v after the pause ended, want right at its end", sent.Sub(pauseEnd))
+    }
+}
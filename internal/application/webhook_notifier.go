@@ -0,0 +1,380 @@
+package application
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "sync"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "time"
+)
+
+// Headers sent with every webhook delivery. The signature is the hex
+// HMAC-SHA256, keyed with the subscription secret, of "<timestamp>.<body>".
+const (
+    WebhookDeliveryHeader  = "X-Webhook-Delivery"
+    WebhookTimestampHeader = "X-Webhook-Timestamp"
+    WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+    webhookBatchSize    = 20
+    webhookMinBackoff   = 10 * time.Second
+    webhookMaxBackoff   = time.Hour
+    webhookPollInterval = 5 * time.Second
+    webhookLogLimit     = 50
+)
+
+var (
+    ErrWebhookNotFound   = errors.New("webhook no encontrado")
+    ErrInvalidWebhookURL = errors.New("la URL del webhook debe ser https")
+    ErrInvalidWebhook    = errors.New("el webhook necesita numero_serie o user_id")
+    ErrWebhookFormat     = errors.New("formato de webhook desconocido")
+
+    errWebhookDeleted = errors.New("la suscripción del webhook ya no existe")
+)
+
+// WebhookNotifier POSTs alerts to the HTTPS endpoints subscribed to a device
+// or to its owner. Deliveries are stored first and sent by a background
+// worker, so they are retried with backoff across restarts. A delivery gives
+// up after maxAttempts, and an endpoint failing disableAfter times in a row
+// is disabled until an admin enables it again.
+type WebhookNotifier struct {
+    webhookManager ports.WebhookManager
+    httpClient     *http.Client
+    maxAttempts    int
+    disableAfter   int
+    now            func() time.Time
+
+    wake     chan struct{}
+    done     chan struct{}
+    stopOnce sync.Once
+    wg       sync.WaitGroup
+}
+
+func NewWebhookNotifier(webhookManager ports.WebhookManager, maxAttempts int, disableAfter int) *WebhookNotifier {
+    return &WebhookNotifier{
+        webhookManager: webhookManager,
+        httpClient:     &http.Client{Timeout: 10 * time.Second},
+        maxAttempts:    maxAttempts,
+        disableAfter:   disableAfter,
+        now:            time.Now,
+        wake:           make(chan struct{}, 1),
+        done:           make(chan struct{}),
+    }
+}
+
+// webhookPayload is the body of "json" deliveries.
+type webhookPayload struct {
+    Event              string `json:"event"`
+    NumeroSerie        string `json:"numero_serie"`
+    Sensor             string `json:"sensor"`
+    Estado             int    `json:"estado"`
+    Severity           string `json:"severity"`
+    FechaActivacion    string `json:"fecha_activacion"`
+    FechaDesactivacion string `json:"fecha_desactivacion"`
+    IncidentID         int    `json:"incident_id,omitempty"`
+    IncidentStatus     string `json:"incident_status,omitempty"`
+    OccurredAt         string `json:"occurred_at"`
+}
+
+func (n *WebhookNotifier) Name() string {
+    return "webhook"
+}
+
+// Deliver queues the alert for every subscription of the device; the
+// deliveries themselves are reported in the delivery log.
+func (n *WebhookNotifier) Deliver(notification domain.AlertNotification) domain.ChannelReport {
+    report := domain.ChannelReport{Channel: n.Name()}
+    queued, err := n.NotifyAlert(notification.Alert, notification.Incident)
+    if err != nil {
+        report.Record(err)
+        return report.Finish(domain.DeliveryQueued)
+    }
+    report.Recipients, report.Delivered = queued, queued
+    return report.Finish(domain.DeliveryQueued)
+}
+
+// NotifyAlert stores a delivery for every subscription of the device, wakes
+// the worker up and returns the number of deliveries queued.
+func (n *WebhookNotifier) NotifyAlert(alert *domain.Alert, incident *domain.Incident) (int, error) {
+    subscriptions, err := n.webhookManager.ListWebhooksForDevice(alert.NumeroSerie)
+    if err != nil || len(subscriptions) == 0 {
+        return 0, err
+    }
+
+    now := n.now().UTC()
+    deliveries := make([]domain.WebhookDelivery, 0, len(subscriptions))
+    for _, subscription := range subscriptions {
+        payload, err := renderWebhookPayload(subscription.Format, alert, incident, now)
+        if err != nil {
+            return 0, err
+        }
+        deliveries = append(deliveries, domain.WebhookDelivery{
+            SubscriptionID: subscription.ID,
+            Payload:        payload,
+            Status:         domain.WebhookDeliveryPending,
+            NextAttemptAt:  now,
+            CreatedAt:      now,
+        })
+    }
+    if err := n.webhookManager.CreateWebhookDeliveries(deliveries); err != nil {
+        return 0, err
+    }
+
+    select {
+    case n.wake <- struct{}{}:
+    default:
+    }
+    return len(deliveries), nil
+}
+
+func renderWebhookPayload(format domain.WebhookFormat, alert *domain.Alert, incident *domain.Incident, now time.Time) ([]byte, error) {
+    if format == domain.WebhookFormatSlack {
+        estado := "desactivado"
+        if alert.Estado == 1 {
+            estado = "activado"
+        }
+        text := fmt.Sprintf("🚨 Sensor %s de %s %s (%s)", alert.Sensor, alert.NumeroSerie, estado, alert.FechaActivacion)
+        if incident != nil {
+            text += fmt.Sprintf(" — incidente #%d: %s", incident.ID, incidentStatusText[incident.Status])
+        }
+        return json.Marshal(map[string]string{"text": text})
+    }
+
+    payload := webhookPayload{
+        Event:              "alert",
+        NumeroSerie:        alert.NumeroSerie,
+        Sensor:             alert.Sensor,
+        Estado:             alert.Estado,
+        Severity:           string(domain.AlertSeverity(alert)),
+        FechaActivacion:    alert.FechaActivacion,
+        FechaDesactivacion: alert.FechaDesactivacion,
+        OccurredAt:         now.Format(time.RFC3339),
+    }
+    if incident != nil {
+        payload.IncidentID = incident.ID
+        payload.IncidentStatus = string(incident.Status)
+    }
+    return json.Marshal(payload)
+}
+
+// Start sends pending deliveries in the background until Stop is called.
+func (n *WebhookNotifier) Start() {
+    n.wg.Add(1)
+    go func() {
+        defer n.wg.Done()
+
+        ticker := time.NewTicker(webhookPollInterval)
+        defer ticker.Stop()
+        for {
+            n.drain()
+            select {
+            case <-n.done:
+                return
+            case <-ticker.C:
+            case <-n.wake:
+            }
+        }
+    }()
+}
+
+// Stop waits for the batch being delivered to finish.
+func (n *WebhookNotifier) Stop() {
+    n.stopOnce.Do(func() { close(n.done) })
+    n.wg.Wait()
+}
+
+func (n *WebhookNotifier) drain() {
+    for {
+        deliveries, err := n.webhookManager.ClaimDueWebhookDeliveries(webhookBatchSize, 5*time.Minute)
+        if err != nil {
+            log.Printf("Error reading webhook deliveries: %v", err)
+            return
+        }
+
+        subscriptions := make(map[int]*domain.WebhookSubscription)
+        for i := range deliveries {
+            delivery := &deliveries[i]
+            subscription, ok := subscriptions[delivery.SubscriptionID]
+            if !ok {
+                if subscription, err = n.webhookManager.GetWebhook(delivery.SubscriptionID); err != nil {
+                    // Retried with backoff like a failed POST.
+                    n.record(delivery, 0, fmt.Errorf("error reading webhook %d: %v", delivery.SubscriptionID, err))
+                    continue
+                }
+                subscriptions[delivery.SubscriptionID] = subscription
+            }
+            if subscription == nil {
+                // Deleted after the delivery was claimed: it can never be sent.
+                n.record(delivery, 0, errWebhookDeleted)
+                continue
+            }
+            n.deliver(subscription, delivery)
+        }
+
+        if len(deliveries) < webhookBatchSize {
+            return
+        }
+        select {
+        case <-n.done:
+            return
+        default:
+        }
+    }
+}
+
+func (n *WebhookNotifier) deliver(subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) {
+    statusCode, err := n.post(subscription, delivery)
+    n.record(delivery, statusCode, err)
+}
+
+// record saves the outcome of an attempt at the delivery: delivered, retried
+// with backoff or, after maxAttempts or once its subscription is gone, failed.
+func (n *WebhookNotifier) record(delivery *domain.WebhookDelivery, statusCode int, err error) {
+    now := n.now().UTC()
+    delivery.Attempts++
+    delivery.LastStatusCode = statusCode
+    switch {
+    case err == nil:
+        delivery.Status = domain.WebhookDeliveryDelivered
+        delivery.LastError = ""
+        delivery.DeliveredAt = &now
+    case delivery.Attempts >= n.maxAttempts || errors.Is(err, errWebhookDeleted):
+        delivery.Status = domain.WebhookDeliveryFailed
+        delivery.LastError = err.Error()
+    default:
+        delivery.LastError = err.Error()
+        delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+    }
+    if err != nil {
+        log.Printf("Webhook %d delivery %d failed (attempt %d): %v", delivery.SubscriptionID, delivery.ID, delivery.Attempts, err)
+    }
+
+    if err := n.webhookManager.RecordWebhookAttempt(delivery, n.disableAfter); err != nil {
+        log.Printf("Error saving webhook delivery %d: %v", delivery.ID, err)
+    }
+}
+
+func (n *WebhookNotifier) post(subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) (int, error) {
+    req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+    if err != nil {
+        return 0, err
+    }
+
+    timestamp := strconv.FormatInt(n.now().Unix(), 10)
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+    req.Header.Set(WebhookTimestampHeader, timestamp)
+    req.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, timestamp, delivery.Payload))
+
+    resp, err := n.httpClient.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+    }
+    return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature receivers must compare against the
+// X-Webhook-Signature header.
+func SignWebhook(secret string, timestamp string, body []byte) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(timestamp + "."))
+    mac.Write(body)
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+    backoff := webhookMinBackoff
+    for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+        backoff *= 2
+    }
+    if backoff > webhookMaxBackoff {
+        backoff = webhookMaxBackoff
+    }
+    return backoff
+}
+
+// Subscribe registers an endpoint for a device or a user; the generated
+// secret is only returned here.
+func (n *WebhookNotifier) Subscribe(subscription domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+    if subscription.ESP32Serial == "" && subscription.UserID == 0 {
+        return nil, ErrInvalidWebhook
+    }
+    u, err := url.Parse(subscription.URL)
+    if err != nil || u.Scheme != "https" || u.Host == "" {
+        return nil, ErrInvalidWebhookURL
+    }
+    if subscription.Format == "" {
+        subscription.Format = domain.WebhookFormatJSON
+    }
+    if subscription.Format != domain.WebhookFormatJSON && subscription.Format != domain.WebhookFormatSlack {
+        return nil, fmt.Errorf("%w: %s", ErrWebhookFormat, subscription.Format)
+    }
+
+    secret, err := randomHex(32)
+    if err != nil {
+        return nil, err
+    }
+    subscription.Secret = secret
+    subscription.CreatedAt = n.now().UTC().Truncate(time.Second)
+    if err := n.webhookManager.CreateWebhook(&subscription); err != nil {
+        return nil, err
+    }
+    return &subscription, nil
+}
+
+func (n *WebhookNotifier) List() ([]domain.WebhookSubscription, error) {
+    return n.webhookManager.ListWebhooks()
+}
+
+func (n *WebhookNotifier) Get(id int) (*domain.WebhookSubscription, error) {
+    subscription, err := n.webhookManager.GetWebhook(id)
+    if err != nil {
+        return nil, err
+    }
+    if subscription == nil {
+        return nil, ErrWebhookNotFound
+    }
+    return subscription, nil
+}
+
+// SetActive enables or disables an endpoint; enabling it clears the failure
+// streak and resumes its pending deliveries.
+func (n *WebhookNotifier) SetActive(id int, active bool) error {
+    if _, err := n.Get(id); err != nil {
+        return err
+    }
+    return n.webhookManager.SetWebhookActive(id, active)
+}
+
+func (n *WebhookNotifier) Delete(id int) error {
+    if _, err := n.Get(id); err != nil {
+        return err
+    }
+    return n.webhookManager.DeleteWebhook(id)
+}
+
+// Deliveries returns the latest deliveries of an endpoint, newest first.
+func (n *WebhookNotifier) Deliveries(id int) ([]domain.WebhookDelivery, error) {
+    if _, err := n.Get(id); err != nil {
+        return nil, err
+    }
+    return n.webhookManager.ListWebhookDeliveries(id, webhookLogLimit)
+}
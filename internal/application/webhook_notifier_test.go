@@ -0,0 +1,88 @@
+package application
+
+import (
+    "errors"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
+    "testing"
+    "time"
+)
+
+// fakeWebhookManager hands out its deliveries once and records the attempts.
+type fakeWebhookManager struct {
+    ports.WebhookManager
+    deliveries    []domain.WebhookDelivery
+    subscriptions map[int]*domain.WebhookSubscription
+    getErr        error
+    attempts      []domain.WebhookDelivery
+}
+
+func (m *fakeWebhookManager) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+    deliveries := m.deliveries
+    m.deliveries = nil
+    return deliveries, nil
+}
+
+func (m *fakeWebhookManager) GetWebhook(id int) (*domain.WebhookSubscription, error) {
+    if m.getErr != nil {
+        return nil, m.getErr
+    }
+    return m.subscriptions[id], nil
+}
+
+func (m *fakeWebhookManager) RecordWebhookAttempt(delivery *domain.WebhookDelivery, disableAfter int) error {
+    m.attempts = append(m.attempts, *delivery)
+    return nil
+}
+
+func TestWebhookNotifierDrainFinishesDeliveriesItCannotSend(t *testing.T) {
+    now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+    tests := []struct {
+        name     string
+        getErr   error
+        status   domain.WebhookDeliveryStatus
+        attempts int
+        retryAt  time.Time
+    }{
+        {
+            name:     "subscription deleted",
+            status:   domain.WebhookDeliveryFailed,
+            attempts: 1,
+        },
+        {
+            name:     "subscription lookup fails",
+            getErr:   errors.New("connection refused"),
+            status:   domain.WebhookDeliveryPending,
+            attempts: 1,
+            retryAt:  now.Add(webhookBackoff(1)),
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            manager := &fakeWebhookManager{
+                deliveries: []domain.WebhookDelivery{{ID: 3, SubscriptionID: 9, Status: domain.WebhookDeliveryPending, NextAttemptAt: now}},
+                getErr:     tt.getErr,
+            }
+            notifier := NewWebhookNotifier(manager, 5, 3)
+            notifier.now = func() time.Time { return now }
+
+            notifier.drain()
+
+            if len(manager.attempts) != 1 {
+                t.Fatalf("recorded %d attempts, want 1", len(manager.attempts))
+            }
+            got := manager.attempts[0]
+            if got.Status != tt.status || got.Attempts != tt.attempts {
+                t.Errorf("delivery status %q after %d attempts, want %q after %d", got.Status, got.Attempts, tt.status, tt.attempts)
+            }
+            if got.LastError == "" {
+                t.Error("delivery has no last error")
+            }
+            if !tt.retryAt.IsZero() && !got.NextAttemptAt.Equal(tt.retryAt) {
+                t.Errorf("next attempt at %v, want %v", got.NextAttemptAt, tt.retryAt)
+            }
+        })
+    }
+}
@@ -0,0 +1,165 @@
+package bot
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "telegramassist/internal/application"
+    "telegramassist/internal/domain"
+    "time"
+
+    tele "gopkg.in/telebot.v3"
+)
+
+// Unique identifiers of the device picker buttons
+const (
+    callbackUnlink        = "dev_unlink"
+    callbackLastAlerta    = "dev_last"
+    callbackLastAlertaAll = "dev_last_all"
+)
+
+func (h *BotHandler) HandleMisDispositivos(c tele.Context) error {
+    statuses, err := h.esp32Service.GetDeviceStatuses(c.Chat().ID)
+    if errors.Is(err, domain.ErrNoDeviceLinked) {
+        return c.Send(noDeviceMessage)
+    }
+    if err != nil {
+        return c.Send("Error al obtener tus dispositivos: " + err.Error())
+    }
+
+    var b strings.Builder
+    b.WriteString("Tus dispositivos:\n")
+    for _, status := range statuses {
+        b.WriteString(formatDeviceStatus(status))
+    }
+    return c.Send(b.String())
+}
+
+func (h *BotHandler) HandleDesvincular(c tele.Context) error {
+    serials, err := h.esp32Service.GetDevicesByChat(c.Chat().ID)
+    if errors.Is(err, domain.ErrNoDeviceLinked) {
+        return c.Send(noDeviceMessage)
+    }
+    if err != nil {
+        return c.Send("Error al obtener tus dispositivos: " + err.Error())
+    }
+    return c.Send("¿Qué ESP32 quieres desvincular?", devicePicker(serials, callbackUnlink))
+}
+
+func (h *BotHandler) HandleUnlinkCallback(c tele.Context) error {
+    serial := c.Callback().Data
+    if err := h.esp32Service.UnlinkESP32(c.Chat().ID, serial); err != nil {
+        return c.Respond(&tele.CallbackResponse{Text: "Error: " + err.Error()})
+    }
+    c.Respond()
+    return c.Edit("ESP32 " + serial + " desvinculado. Ya no recibirás sus alertas.")
+}
+
+func (h *BotHandler) HandleLastAlertaCallback(c tele.Context) error {
+    serial := c.Callback().Data
+    linked, err := h.esp32Service.IsLinked(c.Chat().ID, serial)
+    if err != nil || !linked {
+        return c.Respond(&tele.CallbackResponse{Text: "El ESP32 no está vinculado a este chat"})
+    }
+    c.Respond()
+    return h.sendLastReading(c, serial)
+}
+
+func (h *BotHandler) HandleLastAlertaAllCallback(c tele.Context) error {
+    c.Respond()
+
+    statuses, err := h.esp32Service.GetDeviceStatuses(c.Chat().ID)
+    if errors.Is(err, domain.ErrNoDeviceLinked) {
+        return c.Send(noDeviceMessage)
+    }
+    if err != nil {
+        return c.Send("Error al obtener la última lectura: " + err.Error())
+    }
+
+    summaries := make([]string, 0, len(statuses))
+    for _, status := range statuses {
+        summaries = append(summaries, formatReadingSummary(status.Serial, status.LastReading))
+    }
+    return c.Send(strings.Join(summaries, "\n\n"))
+}
+
+func (h *BotHandler) sendLastReading(c tele.Context, serial string) error {
+    reading, err := h.ky026Service.GetLastReading(serial)
+    if err != nil {
+        return c.Send("Error al obtener la última lectura: " + err.Error())
+    }
+    return c.Send(formatReadingSummary(serial, reading))
+}
+
+// formatReadingSummary describes the last reading of a device for humans.
+func formatReadingSummary(serial string, reading *domain.KY026Reading) string {
+    if reading == nil {
+        return "📟 ESP32 " + serial + "\nNo se encontraron lecturas."
+    }
+
+    estado := "✅ Desactivado"
+    if reading.Estado == "1" {
+        estado = "🔥 Activado (fuego o humo detectado)"
+    }
+    return "📟 ESP32 " + serial + "\n" +
+        "Estado: " + estado + "\n" +
+        "Fecha: " + humanizeFecha(reading.FechaActivacion)
+}
+
+// humanizeFecha appends how long ago the reading happened when the device
+// sent the date in the usual domain.FechaLayout format.
+func humanizeFecha(fecha string) string {
+    t, err := time.ParseInLocation(domain.FechaLayout, fecha, time.Local)
+    if err != nil {
+        return fecha
+    }
+
+    ago := time.Since(t)
+    switch {
+    case ago < 0:
+        return fecha
+    case ago < time.Minute:
+        return fecha + " (hace unos segundos)"
+    case ago < time.Hour:
+        return fmt.Sprintf("%s (hace %d min)", fecha, int(ago.Minutes()))
+    case ago < 48*time.Hour:
+        return fmt.Sprintf("%s (hace %d h)", fecha, int(ago.Hours()))
+    default:
+        return fmt.Sprintf("%s (hace %d días)", fecha, int(ago.Hours()/24))
+    }
+}
+
+// devicePicker shows one button per serial plus any extra buttons, each in
+// its own row.
+func devicePicker(serials []string, unique string, extra ...tele.Btn) *tele.ReplyMarkup {
+    markup := &tele.ReplyMarkup{}
+    rows := make([]tele.Row, 0, len(serials)+len(extra))
+    for _, serial := range serials {
+        rows = append(rows, markup.Row(markup.Data(serial, unique, serial)))
+    }
+    for _, btn := range extra {
+        rows = append(rows, markup.Row(btn))
+    }
+    markup.Inline(rows...)
+    return markup
+}
+
+func formatDeviceStatus(status application.DeviceStatus) string {
+    line := "\n📟 " + status.Serial + "\n"
+    if status.LastReading == nil {
+        line += "   Sin lecturas\n"
+    } else {
+        line += fmt.Sprintf("   Última lectura: %s (%s)\n", status.LastReading.FechaActivacion, estadoText(status.LastReading.Estado))
+    }
+    if status.OpenIncident != nil {
+        line += fmt.Sprintf("   🔥 Incidente #%d abierto\n", status.OpenIncident.ID)
+    }
+    return line
+}
+
+func estadoText(estado string) string {
+    if estado == "1" {
+        return "Activado"
+    }
+    return "Desactivado"
+}
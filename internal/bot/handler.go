@@ -1,50 +1,71 @@
 package bot
 
 import (
+    "errors"
     "telegramassist/internal/application"
+    "telegramassist/internal/domain"
     tele "gopkg.in/telebot.v3"
     "os"
     "time"
     "log"
-	strconv "strconv"
 )
 
 type BotHandler struct {
-    esp32Service *application.ESP32Service
-    ky026Service *application.KY026Service
-    Bot          *tele.Bot
-    userStates   map[int64]string
-    tempData     map[int64]string
+    esp32Service        *application.ESP32Service
+    ky026Service        *application.KY026Service
+    incidentService     *application.IncidentService
+    notificationService *application.NotificationService
+    Bot                 *tele.Bot
+    userStates          map[int64]string
+    tempData            map[int64]string
+    histories           *historyStore
 }
 
-func NewBotHandler(esp32Service *application.ESP32Service, ky026Service *application.KY026Service) *BotHandler {
+func NewBotHandler(esp32Service *application.ESP32Service, ky026Service *application.KY026Service, incidentService *application.IncidentService) *BotHandler {
     return &BotHandler{
-        esp32Service: esp32Service,
-        ky026Service: ky026Service,
-        userStates:   make(map[int64]string),
-        tempData:     make(map[int64]string),
+        esp32Service:    esp32Service,
+        ky026Service:    ky026Service,
+        incidentService: incidentService,
+        userStates:      make(map[int64]string),
+        tempData:        make(map[int64]string),
+        histories:       newHistoryStore(),
     }
 }
 
+// SetNotificationService provides the service used to edit alert messages.
+// It must be called before Start since the service needs the bot created by Init.
+func (h *BotHandler) SetNotificationService(notificationService *application.NotificationService) {
+    h.notificationService = notificationService
+}
+
+const noDeviceMessage = "No tienes ningún ESP32 registrado. Por favor, usa /registrar primero para vincular tu dispositivo."
+
 func (h *BotHandler) HandleUltimaAlerta(c tele.Context) error {
     chatID := c.Chat().ID
-  
-    reading, err := h.ky026Service.GetLastReading(strconv.FormatInt(chatID, 10))
+
+    serials, err := h.esp32Service.GetDevicesByChat(chatID)
+    if errors.Is(err, domain.ErrNoDeviceLinked) {
+        return c.Send(noDeviceMessage)
+    }
     if err != nil {
-        if err.Error() == "No hay un ESP32 registrado para este chat" {
-            return c.Send("No tienes ningún ESP32 registrado. Por favor, usa /registrar primero para vincular tu dispositivo.")
-        }
         return c.Send("Error al obtener la última lectura: " + err.Error())
     }
-    if reading == nil {
-        return c.Send("No se encontraron lecturas para tu ESP32.")
+
+    if len(serials) > 1 {
+        all := tele.Btn{Text: "📋 Todos", Unique: callbackLastAlertaAll}
+        return c.Send("¿De qué ESP32 quieres ver la última alerta?", devicePicker(serials, callbackLastAlerta, all))
+    }
+
+    serial := serials[0]
+    reading, err := h.esp32Service.GetLastKY026Reading(serial)
+    if err != nil {
+        return c.Send("Error al obtener la última lectura: " + err.Error())
     }
-    return c.Send("Última lectura del sensor:\n" +
-        "Fecha: " + reading.FechaActivacion + "\n" +
-        "Estado: " + reading.Estado)
+    return c.Send(formatReadingSummary(serial, reading))
 }
 
-func (h *BotHandler) Start() {
+// Init creates the Telegram bot and registers the command handlers.
+func (h *BotHandler) Init() {
     pref := tele.Settings{
         Token:  os.Getenv("TELEGRAM_BOT_TOKEN"),
         Poller: &tele.LongPoller{Timeout: 10 * time.Second},
@@ -57,18 +78,32 @@ func (h *BotHandler) Start() {
     }
     h.Bot = bot
 
-	
     h.setupCommands()
+}
 
+// Start begins polling for updates in the background.
+func (h *BotHandler) Start() {
     log.Println("Bot iniciado...")
-    h.Bot.Start()
+    go h.Bot.Start()
 }
 
 func (h *BotHandler) setupCommands() {
     h.Bot.Handle("/start", h.HandleStart)
     h.Bot.Handle("/registrar", h.HandleRegistrar)
     h.Bot.Handle("/ultimaalerta", h.HandleUltimaAlerta)
+    h.Bot.Handle("/misdispositivos", h.HandleMisDispositivos)
+    h.Bot.Handle("/desvincular", h.HandleDesvincular)
+    h.Bot.Handle("/historial", h.HandleHistorial)
     h.Bot.Handle(tele.OnText, h.HandleText)
+
+    h.Bot.Handle(&tele.Btn{Unique: application.CallbackAcknowledge}, h.HandleAcknowledge)
+    h.Bot.Handle(&tele.Btn{Unique: application.CallbackFalseAlarm}, h.HandleFalseAlarm)
+    h.Bot.Handle(&tele.Btn{Unique: application.CallbackCallContact}, h.HandleCallContact)
+    h.Bot.Handle(&tele.Btn{Unique: callbackUnlink}, h.HandleUnlinkCallback)
+    h.Bot.Handle(&tele.Btn{Unique: callbackLastAlerta}, h.HandleLastAlertaCallback)
+    h.Bot.Handle(&tele.Btn{Unique: callbackLastAlertaAll}, h.HandleLastAlertaAllCallback)
+    h.Bot.Handle(&tele.Btn{Unique: callbackHistoryDevice}, h.HandleHistoryDeviceCallback)
+    h.Bot.Handle(&tele.Btn{Unique: callbackHistoryPage}, h.HandleHistoryPageCallback)
 }
 
 // Define command handlers (HandleStart, HandleRegistrar, HandleUltimaAlerta, HandleText)
@@ -76,7 +111,10 @@ func (h *BotHandler) HandleStart(c tele.Context) error {
 	h.userStates[c.Chat().ID] = ""
 	return c.Send("¡Bienvenido! Para registrar tu ESP32, usa uno de los siguientes comandos:\n\n" +
 		"/registrar - Registrar un nuevo producto ESP32\n" +
-		"/ultimaalerta - Ver la última alerta de tu sensor")
+		"/ultimaalerta - Ver la última alerta de tu sensor\n" +
+		"/misdispositivos - Ver tus ESP32 vinculados y su estado\n" +
+		"/desvincular - Desvincular un ESP32 de este chat\n" +
+		"/historial [hoy|7d|AAAA-MM-DD] - Ver el historial de lecturas")
 }
 
 func (h *BotHandler) HandleRegistrar(c tele.Context) error {
@@ -84,23 +122,6 @@ func (h *BotHandler) HandleRegistrar(c tele.Context) error {
 	return c.Send("Por favor, ingresa el número de serial de tu ESP32:")
 }
 
-func (h *BotHandler) HandleUltimaAlertaV2(c tele.Context) error {
-	chatID := c.Chat().ID
-reading, err := h.ky026Service.GetLastReading(strconv.FormatInt(chatID, 10))
-	if err != nil {
-		if err.Error() == "No hay un ESP32 registrado para este chat" {
-			return c.Send("No tienes ningún ESP32 registrado. Por favor, usa /registrar primero para vincular tu dispositivo.")
-		}
-		return c.Send("Error al obtener la última lectura: " + err.Error())
-	}
-	if reading == nil {
-		return c.Send("No se encontraron lecturas para tu ESP32.")
-	}
-	return c.Send("Última lectura del sensor:\n" +
-		"Fecha: " + reading.FechaActivacion + "\n" +
-		"Estado: " + reading.Estado)
-}
-
 func (h *BotHandler) HandleText(c tele.Context) error {
 	chatID := c.Chat().ID
 	state := h.userStates[chatID]
@@ -0,0 +1,235 @@
+package bot
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "sync"
+    "telegramassist/internal/domain"
+    "time"
+
+    tele "gopkg.in/telebot.v3"
+)
+
+const (
+    historyPageSize = 10
+    historyTTL      = 24 * time.Hour
+
+    callbackHistoryDevice = "hist_dev"
+    callbackHistoryPage   = "hist_page"
+)
+
+// historyQuery is the /historial query shown by a message.
+type historyQuery struct {
+    Serial string
+    From   time.Time
+    To     time.Time
+    Label  string
+}
+
+// historyKey identifies the message a /historial query is shown in.
+type historyKey struct {
+    chatID    int64
+    messageID int
+}
+
+type historyEntry struct {
+    query    historyQuery
+    storedAt time.Time
+}
+
+// historyStore keeps the query of each /historial message so its
+// pagination buttons only need to carry the page number, and the buttons of
+// an older message keep paging through its own query. Queries are forgotten
+// after historyTTL.
+type historyStore struct {
+    mu      sync.Mutex
+    entries map[historyKey]historyEntry
+}
+
+func newHistoryStore() *historyStore {
+    return &historyStore{entries: make(map[historyKey]historyEntry)}
+}
+
+func (s *historyStore) set(msg *tele.Message, query historyQuery) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    now := time.Now()
+    for key, entry := range s.entries {
+        if now.Sub(entry.storedAt) > historyTTL {
+            delete(s.entries, key)
+        }
+    }
+    s.entries[historyKey{msg.Chat.ID, msg.ID}] = historyEntry{query: query, storedAt: now}
+}
+
+func (s *historyStore) get(msg *tele.Message) (historyQuery, bool) {
+    if msg == nil {
+        return historyQuery{}, false
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    entry, ok := s.entries[historyKey{msg.Chat.ID, msg.ID}]
+    return entry.query, ok
+}
+
+// HandleHistorial shows the readings of a device. Accepted ranges:
+// "hoy", "<n>d" (e.g. "7d"), "AAAA-MM-DD" or "AAAA-MM-DD AAAA-MM-DD".
+// Without arguments the last 7 days are shown.
+func (h *BotHandler) HandleHistorial(c tele.Context) error {
+    chatID := c.Chat().ID
+
+    from, to, label, err := parseHistoryRange(c.Args(), time.Now())
+    if err != nil {
+        return c.Send(err.Error() + "\n\nUso: /historial [hoy | 7d | AAAA-MM-DD | AAAA-MM-DD AAAA-MM-DD]")
+    }
+
+    serials, err := h.esp32Service.GetDevicesByChat(chatID)
+    if errors.Is(err, domain.ErrNoDeviceLinked) {
+        return c.Send(noDeviceMessage)
+    }
+    if err != nil {
+        return c.Send("Error al obtener el historial: " + err.Error())
+    }
+
+    query := historyQuery{From: from, To: to, Label: label}
+    if len(serials) > 1 {
+        msg, err := c.Bot().Send(c.Chat(), "¿De qué ESP32 quieres ver el historial?", devicePicker(serials, callbackHistoryDevice))
+        if err != nil {
+            return err
+        }
+        h.histories.set(msg, query)
+        return nil
+    }
+
+    query.Serial = serials[0]
+    text, markup, err := h.renderHistoryPage(query, 0)
+    if err != nil {
+        return c.Send("Error al obtener el historial: " + err.Error())
+    }
+    msg, err := c.Bot().Send(c.Chat(), text, markup)
+    if err != nil {
+        return err
+    }
+    h.histories.set(msg, query)
+    return nil
+}
+
+func (h *BotHandler) HandleHistoryDeviceCallback(c tele.Context) error {
+    chatID := c.Chat().ID
+    serial := c.Callback().Data
+
+    query, ok := h.histories.get(c.Callback().Message)
+    linked, err := h.esp32Service.IsLinked(chatID, serial)
+    if !ok || err != nil || !linked {
+        return c.Respond(&tele.CallbackResponse{Text: "La consulta expiró, usa /historial de nuevo"})
+    }
+
+    // The picker message is edited into the history, so it keeps the query.
+    query.Serial = serial
+    h.histories.set(c.Callback().Message, query)
+    c.Respond()
+    return h.editHistoryPage(c, query, 0)
+}
+
+func (h *BotHandler) HandleHistoryPageCallback(c tele.Context) error {
+    query, ok := h.histories.get(c.Callback().Message)
+    page, err := strconv.Atoi(c.Callback().Data)
+    if !ok || query.Serial == "" || err != nil {
+        return c.Respond(&tele.CallbackResponse{Text: "La consulta expiró, usa /historial de nuevo"})
+    }
+
+    c.Respond()
+    return h.editHistoryPage(c, query, page)
+}
+
+func (h *BotHandler) editHistoryPage(c tele.Context, query historyQuery, page int) error {
+    text, markup, err := h.renderHistoryPage(query, page)
+    if err != nil {
+        return c.Send("Error al obtener el historial: " + err.Error())
+    }
+    return c.Edit(text, markup)
+}
+
+// renderHistoryPage returns the text of a page and its navigation buttons,
+// nil when everything fits in one page.
+func (h *BotHandler) renderHistoryPage(query historyQuery, page int) (string, *tele.ReplyMarkup, error) {
+    result, err := h.ky026Service.GetReadingHistory(query.Serial, query.From, query.To, page, historyPageSize)
+    if err != nil {
+        return "", nil, err
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "📜 Historial de %s (%s)\n", query.Serial, query.Label)
+    fmt.Fprintf(&b, "Lecturas: %d — Activaciones: %d\n", result.Total, result.Activations)
+    if result.Total == 0 {
+        b.WriteString("\nNo hay lecturas en este periodo.")
+        return b.String(), nil, nil
+    }
+
+    b.WriteString("\n")
+    for i, reading := range result.Readings {
+        fmt.Fprintf(&b, "%d. %s — %s\n", page*historyPageSize+i+1, reading.FechaActivacion, estadoText(reading.Estado))
+    }
+
+    pages := (result.Total + historyPageSize - 1) / historyPageSize
+    fmt.Fprintf(&b, "\nPágina %d de %d", page+1, pages)
+
+    markup := &tele.ReplyMarkup{}
+    var buttons []tele.Btn
+    if page > 0 {
+        buttons = append(buttons, markup.Data("◀️ Anterior", callbackHistoryPage, strconv.Itoa(page-1)))
+    }
+    if page+1 < pages {
+        buttons = append(buttons, markup.Data("Siguiente ▶️", callbackHistoryPage, strconv.Itoa(page+1)))
+    }
+    if len(buttons) == 0 {
+        return b.String(), nil, nil
+    }
+    markup.Inline(markup.Row(buttons...))
+    return b.String(), markup, nil
+}
+
+// parseHistoryRange turns the /historial arguments into a [from, to) range.
+func parseHistoryRange(args []string, now time.Time) (time.Time, time.Time, string, error) {
+    today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+    switch len(args) {
+    case 0:
+        return now.AddDate(0, 0, -7), time.Time{}, "últimos 7 días", nil
+    case 1:
+        arg := strings.ToLower(args[0])
+        if arg == "hoy" {
+            return today, time.Time{}, "hoy", nil
+        }
+        if strings.HasSuffix(arg, "d") {
+            days, err := strconv.Atoi(strings.TrimSuffix(arg, "d"))
+            if err != nil || days <= 0 {
+                return time.Time{}, time.Time{}, "", fmt.Errorf("Rango no válido: %s", args[0])
+            }
+            return now.AddDate(0, 0, -days), time.Time{}, fmt.Sprintf("últimos %d días", days), nil
+        }
+        day, err := time.ParseInLocation("2006-01-02", arg, now.Location())
+        if err != nil {
+            return time.Time{}, time.Time{}, "", fmt.Errorf("Fecha no válida: %s", args[0])
+        }
+        return day, day.AddDate(0, 0, 1), args[0], nil
+    case 2:
+        from, err := time.ParseInLocation("2006-01-02", args[0], now.Location())
+        if err != nil {
+            return time.Time{}, time.Time{}, "", fmt.Errorf("Fecha no válida: %s", args[0])
+        }
+        until, err := time.ParseInLocation("2006-01-02", args[1], now.Location())
+        if err != nil {
+            return time.Time{}, time.Time{}, "", fmt.Errorf("Fecha no válida: %s", args[1])
+        }
+        if until.Before(from) {
+            return time.Time{}, time.Time{}, "", errors.New("La fecha final es anterior a la inicial")
+        }
+        return from, until.AddDate(0, 0, 1), args[0] + " a " + args[1], nil
+    default:
+        return time.Time{}, time.Time{}, "", errors.New("Demasiados argumentos")
+    }
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "telegramassist/internal/application"
+    "telegramassist/internal/domain"
+
+    tele "gopkg.in/telebot.v3"
+)
+
+func (h *BotHandler) HandleAcknowledge(c tele.Context) error {
+    return h.handleIncidentAction(c, domain.IncidentAcknowledged)
+}
+
+func (h *BotHandler) HandleFalseAlarm(c tele.Context) error {
+    return h.handleIncidentAction(c, domain.IncidentFalseAlarm)
+}
+
+func (h *BotHandler) HandleCallContact(c tele.Context) error {
+    incident, err := h.callbackIncident(c)
+    if err != nil || incident == nil {
+        return c.Respond(&tele.CallbackResponse{Text: "Incidente no encontrado"})
+    }
+
+    contact := os.Getenv("EMERGENCY_CONTACT")
+    device, err := h.esp32Service.GetDevice(incident.ESP32Serial)
+    if err == nil && device != nil && device.EmergencyContact != "" {
+        contact = device.EmergencyContact
+    }
+    if contact == "" {
+        return c.Respond(&tele.CallbackResponse{Text: "No hay un contacto de emergencia configurado", ShowAlert: true})
+    }
+
+    c.Respond()
+    return c.Send(fmt.Sprintf("📞 Contacto de emergencia para el incidente #%d: %s", incident.ID, contact))
+}
+
+func (h *BotHandler) handleIncidentAction(c tele.Context, status domain.IncidentStatus) error {
+    incident, err := h.callbackIncident(c)
+    if err != nil || incident == nil {
+        return c.Respond(&tele.CallbackResponse{Text: "Incidente no encontrado"})
+    }
+
+    sender := c.Sender()
+    incident, err = h.incidentService.Transition(incident.ID, status, telegramActor(sender))
+    if errors.Is(err, domain.ErrInvalidTransition) {
+        return c.Respond(&tele.CallbackResponse{Text: "Este incidente ya fue atendido o cerrado"})
+    }
+    if err != nil {
+        return c.Respond(&tele.CallbackResponse{Text: "Error al actualizar el incidente: " + err.Error()})
+    }
+
+    if err := h.notificationService.UpdateIncidentMessages(incident, displayName(sender)); err != nil {
+        log.Printf("Error updating messages of incident %d: %v", incident.ID, err)
+    }
+    return c.Respond(&tele.CallbackResponse{
+        Text: fmt.Sprintf("Incidente #%d actualizado", incident.ID),
+    })
+}
+
+// callbackIncident loads the incident referenced by the pressed button and
+// checks that the chat was notified about it.
+func (h *BotHandler) callbackIncident(c tele.Context) (*domain.Incident, error) {
+    id, err := strconv.Atoi(c.Callback().Data)
+    if err != nil {
+        return nil, err
+    }
+
+    received, err := h.incidentService.ChatReceivedIncident(id, c.Chat().ID)
+    if err != nil {
+        return nil, err
+    }
+    if !received {
+        return nil, application.ErrIncidentNotFound
+    }
+    return h.incidentService.GetIncident(id)
+}
+
+func telegramActor(user *tele.User) string {
+    return fmt.Sprintf("telegram:%d (%s)", user.ID, displayName(user))
+}
+
+func displayName(user *tele.User) string {
+    if user.Username != "" {
+        return "@" + user.Username
+    }
+    return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
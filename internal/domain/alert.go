@@ -1,10 +1,63 @@
 package domain
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const maxEventIDLength = 64
+
+// ErrInvalidAlert is wrapped by the errors of Alert.Validate.
+var ErrInvalidAlert = errors.New("alerta no válida")
 
 type Alert struct {
+	// EventID optionally identifies the event on the device, so retries of
+	// the same alert can be recognised.
+	EventID            string `json:"event_id,omitempty"`
 	NumeroSerie        string `json:"numeroSerie"`
 	Sensor             string `json:"sensor"`
 	FechaActivacion    string `json:"fecha_activacion"`
 	FechaDesactivacion string `json:"fecha_desactivacion"`
 	Estado             int    `json:"estado"`
-}
\ No newline at end of file
+}
+
+// Validate checks the fields every alert must have, whatever transport it
+// arrived by. An alert with no event ID must carry its activation date in
+// FechaLayout, since its dedup key is built from it.
+func (a *Alert) Validate() error {
+	switch {
+	case a.NumeroSerie == "":
+		return fmt.Errorf("%w: falta numeroSerie", ErrInvalidAlert)
+	case a.Sensor == "":
+		return fmt.Errorf("%w: falta sensor", ErrInvalidAlert)
+	case a.Estado != 0 && a.Estado != 1:
+		return fmt.Errorf("%w: estado debe ser 0 o 1", ErrInvalidAlert)
+	case len(a.EventID) > maxEventIDLength:
+		return fmt.Errorf("%w: event_id supera %d caracteres", ErrInvalidAlert, maxEventIDLength)
+	case a.EventID == "" && a.FechaActivacion == "":
+		return fmt.Errorf("%w: falta fecha_activacion o event_id", ErrInvalidAlert)
+	}
+	if a.EventID == "" {
+		if _, err := time.Parse(FechaLayout, a.FechaActivacion); err != nil {
+			return fmt.Errorf("%w: fecha_activacion debe tener el formato %s", ErrInvalidAlert, FechaLayout)
+		}
+	}
+	return nil
+}
+
+// DedupKey identifies the event an alert reports: its event ID, scoped to
+// the device, or else the device, sensor, activation date and estado. The
+// estado is part of the key because a deactivation can carry the same
+// activation date as the activation it ends.
+func (a *Alert) DedupKey() string {
+	h := sha256.New()
+	if a.EventID != "" {
+		fmt.Fprintf(h, "event\x00%s\x00%s", a.NumeroSerie, a.EventID)
+	} else {
+		fmt.Fprintf(h, "reading\x00%s\x00%s\x00%s\x00%d", a.NumeroSerie, a.Sensor, a.FechaActivacion, a.Estado)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
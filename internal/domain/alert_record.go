@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+type AlertStatus string
+
+const (
+	AlertQueued AlertStatus = "queued"
+	// AlertProcessing is the checkpoint saved with the reading and the
+	// outbox: the incident was handled and the alert is being notified. A
+	// retry of a processing alert only marks it processed.
+	AlertProcessing AlertStatus = "processing"
+	AlertProcessed  AlertStatus = "processed"
+	AlertFailed     AlertStatus = "failed"
+	// AlertFlappingPending is a processed alert the flap detector suppressed.
+	// It is replayed as settled at SettleAt unless a newer alert of its
+	// sensor supersedes it first.
+	AlertFlappingPending AlertStatus = "flapping_pending"
+)
+
+// AlertRecord is an alert accepted for asynchronous processing and what
+// became of it.
+type AlertRecord struct {
+	ID          int
+	Alert       Alert
+	Status      AlertStatus
+	Attempts    int
+	IncidentID  int
+	Delivery    *DeliveryReport
+	LastError   string
+	ReceivedAt  time.Time
+	ProcessedAt *time.Time
+	// ReadingStored is set for the alerts of a batch, whose readings are
+	// stored with them; they are processed and notified per device.
+	ReadingStored bool
+	SettleAt      *time.Time
+	// OutboxQueued is the number of RabbitMQ messages written to the outbox
+	// with the alert.
+	OutboxQueued int
+	// NotifyPending is set by the checkpoint of an alert to be notified and
+	// cleared once it is processed, so an alert found processing is notified
+	// again. IncidentOpened is kept for that notification.
+	NotifyPending  bool
+	IncidentOpened bool
+}
+
+// Due reports whether the alert is to be processed at the given time, which
+// flapping pending alerts only are once they settle.
+func (r *AlertRecord) Due(now time.Time) bool {
+	switch r.Status {
+	case AlertQueued, AlertProcessing:
+		return true
+	case AlertFlappingPending:
+		return r.SettleAt == nil || !r.SettleAt.After(now)
+	default:
+		return false
+	}
+}
+
+// AlertCheckpoint is what processing alerts stores in a single transaction:
+// the reading, the outbox messages and the processing status with the
+// incident of the records. A record with a SettleAt was suppressed by the
+// flap detector and is held as AlertFlappingPending until then instead.
+// Either way it supersedes the flapping pending alerts of its sensor stored
+// before it.
+type AlertCheckpoint struct {
+	Records    []*AlertRecord
+	IncidentID int
+	Reading    *Alert // stored when not nil
+	Outbox     []OutboxMessage
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAlertDedupKey(t *testing.T) {
+	base := Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", FechaActivacion: "2024-05-01 10:00:00", Estado: 1}
+	with := func(edit func(a *Alert)) Alert {
+		a := base
+		edit(&a)
+		return a
+	}
+
+	tests := []struct {
+		name string
+		a, b Alert
+		same bool
+	}{
+		{"same reading", base, base, true},
+		{"deactivation of the same activation", base, with(func(a *Alert) { a.Estado = 0 }), false},
+		{"other activation date", base, with(func(a *Alert) { a.FechaActivacion = "2024-05-01 10:00:01" }), false},
+		{"other sensor", base, with(func(a *Alert) { a.Sensor = "MQ2" }), false},
+		{"other device", base, with(func(a *Alert) { a.NumeroSerie = "ESP32-002" }), false},
+		{"deactivation date is not part of the key", base, with(func(a *Alert) { a.FechaDesactivacion = "2024-05-01 10:05:00" }), true},
+		{
+			"same event ID with other fields",
+			with(func(a *Alert) { a.EventID = "42" }),
+			with(func(a *Alert) { a.EventID = "42"; a.Estado = 0; a.FechaActivacion = "ayer" }),
+			true,
+		},
+		{
+			"same event ID on another device",
+			with(func(a *Alert) { a.EventID = "42" }),
+			with(func(a *Alert) { a.EventID = "42"; a.NumeroSerie = "ESP32-002" }),
+			false,
+		},
+		{"event ID against derived key", base, with(func(a *Alert) { a.EventID = "42" }), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := tt.a.DedupKey(), tt.b.DedupKey()
+			if len(a) != 64 {
+				t.Errorf("DedupKey() = %q, want 64 hex characters", a)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("DedupKey() equal = %v, want %v (%s, %s)", a == b, tt.same, a, b)
+			}
+		})
+	}
+}
+
+func TestAlertValidate(t *testing.T) {
+	base := Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", FechaActivacion: "2024-05-01 10:00:00", Estado: 1}
+	with := func(edit func(a *Alert)) Alert {
+		a := base
+		edit(&a)
+		return a
+	}
+
+	tests := []struct {
+		name  string
+		alert Alert
+		valid bool
+	}{
+		{"complete reading", base, true},
+		{"missing serial", with(func(a *Alert) { a.NumeroSerie = "" }), false},
+		{"missing sensor", with(func(a *Alert) { a.Sensor = "" }), false},
+		{"estado out of range", with(func(a *Alert) { a.Estado = 2 }), false},
+		{"missing activation date", with(func(a *Alert) { a.FechaActivacion = "" }), false},
+		{"activation date in another format", with(func(a *Alert) { a.FechaActivacion = "01/05/2024 10:00" }), false},
+		{"event ID without activation date", with(func(a *Alert) { a.EventID = "42"; a.FechaActivacion = "" }), true},
+		{"event ID with a free-form date", with(func(a *Alert) { a.EventID = "42"; a.FechaActivacion = "ayer" }), true},
+		{"event ID too long", with(func(a *Alert) { a.EventID = string(make([]byte, maxEventIDLength+1)) }), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.alert.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidAlert) {
+				t.Errorf("Validate() = %v, want it to wrap ErrInvalidAlert", err)
+			}
+		})
+	}
+}
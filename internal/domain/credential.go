@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// DeviceCredential is a key an ESP32 uses to sign its alerts. During a
+// rotation the previous key keeps working until ExpiresAt.
+type DeviceCredential struct {
+	ID          int
+	ESP32Serial string
+	KeyID       string
+	Secret      string
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	RevokedAt   *time.Time
+}
+
+// ActiveAt reports whether the credential can be used to sign at t.
+func (c DeviceCredential) ActiveAt(t time.Time) bool {
+	if c.RevokedAt != nil {
+		return false
+	}
+	return c.ExpiresAt == nil || t.Before(*c.ExpiresAt)
+}
+
+// Status returns a human readable state for the credential at t.
+func (c DeviceCredential) Status(t time.Time) string {
+	switch {
+	case c.RevokedAt != nil:
+		return "revoked"
+	case c.ExpiresAt != nil && !t.Before(*c.ExpiresAt):
+		return "expired"
+	case c.ExpiresAt != nil:
+		return "expiring"
+	default:
+		return "active"
+	}
+}
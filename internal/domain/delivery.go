@@ -0,0 +1,98 @@
+package domain
+
+type DeliveryStatus string
+
+const (
+	DeliverySent    DeliveryStatus = "sent"    // every recipient got it
+	DeliveryQueued  DeliveryStatus = "queued"  // stored to be sent in the background
+	DeliveryPartial DeliveryStatus = "partial" // some recipients failed
+	DeliveryFailed  DeliveryStatus = "failed"
+	DeliverySkipped DeliveryStatus = "skipped" // nothing to send (no recipients, disabled...)
+)
+
+// AlertNotification is an alert ready to be sent through the notification
+// channels, together with the recipients looked up by the dispatcher.
+type AlertNotification struct {
+	Alert          *Alert
+	Incident       *Incident
+	IncidentOpened bool
+	OutboxQueued   int // mensajes RabbitMQ escritos en el outbox junto con la lectura
+	// Suppressed is the reason the alert is not to be sent at all, e.g. a
+	// flapping sensor; channels only report it as skipped.
+	Suppressed string
+	// Coalesced is the number of alerts of the device this notification
+	// stands for when they arrived in a batch; Alert is the last one.
+	Coalesced int
+
+	ChatIDs     []int64
+	Owner       *User // nil when the device has no owner
+	Preferences NotificationPreferences
+}
+
+// ChannelReport is the outcome of one notification channel.
+type ChannelReport struct {
+	Channel    string         `json:"channel"`
+	Status     DeliveryStatus `json:"status"`
+	Recipients int            `json:"recipients"`
+	Delivered  int            `json:"delivered"`
+	Reason     string         `json:"reason,omitempty"`
+	Errors     []string       `json:"errors,omitempty"`
+}
+
+func SkippedReport(channel string, reason string) ChannelReport {
+	return ChannelReport{Channel: channel, Status: DeliverySkipped, Reason: reason}
+}
+
+// Record counts the outcome of sending to one recipient.
+func (r *ChannelReport) Record(err error) {
+	r.Recipients++
+	if err != nil {
+		r.Errors = append(r.Errors, err.Error())
+		return
+	}
+	r.Delivered++
+}
+
+// Finish sets the status from the recorded outcomes; success is either
+// DeliverySent or DeliveryQueued.
+func (r ChannelReport) Finish(success DeliveryStatus) ChannelReport {
+	switch {
+	case r.Recipients == 0:
+		r.Status = DeliverySkipped
+		if r.Reason == "" {
+			r.Reason = "no recipients"
+		}
+	case r.Delivered == r.Recipients:
+		r.Status = success
+	case r.Delivered == 0:
+		r.Status = DeliveryFailed
+	default:
+		r.Status = DeliveryPartial
+	}
+	return r
+}
+
+// DeliveryReport collects the outcome of every channel for one alert.
+type DeliveryReport struct {
+	Channels []ChannelReport `json:"channels"`
+}
+
+// Channel returns the report of the named channel.
+func (r DeliveryReport) Channel(name string) (ChannelReport, bool) {
+	for _, c := range r.Channels {
+		if c.Channel == name {
+			return c, true
+		}
+	}
+	return ChannelReport{}, false
+}
+
+// Failed reports whether any channel failed, even partially.
+func (r DeliveryReport) Failed() bool {
+	for _, c := range r.Channels {
+		if c.Status == DeliveryFailed || c.Status == DeliveryPartial {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+type EscalationAction string
+
+const (
+	EscalationRenotify  EscalationAction = "renotify"
+	EscalationSecondary EscalationAction = "secondary"
+	EscalationWebhook   EscalationAction = "webhook"
+)
+
+// EscalationPolicy says what to do, per device, when an incident stays open.
+// Delays are measured from the moment the incident was opened; a zero delay
+// disables the step.
+type EscalationPolicy struct {
+	ESP32Serial      string
+	RenotifyAfter    time.Duration
+	SecondaryAfter   time.Duration
+	WebhookAfter     time.Duration
+	SecondaryChatIDs []int64
+	WebhookURL       string
+}
+
+type EscalationStep struct {
+	Action EscalationAction
+	Delay  time.Duration
+}
+
+// Steps returns the enabled steps of the ladder in order.
+func (p EscalationPolicy) Steps() []EscalationStep {
+	var steps []EscalationStep
+	if p.RenotifyAfter > 0 {
+		steps = append(steps, EscalationStep{Action: EscalationRenotify, Delay: p.RenotifyAfter})
+	}
+	if p.SecondaryAfter > 0 && len(p.SecondaryChatIDs) > 0 {
+		steps = append(steps, EscalationStep{Action: EscalationSecondary, Delay: p.SecondaryAfter})
+	}
+	if p.WebhookAfter > 0 && p.WebhookURL != "" {
+		steps = append(steps, EscalationStep{Action: EscalationWebhook, Delay: p.WebhookAfter})
+	}
+	return steps
+}
+
+type EscalationStatus string
+
+const (
+	EscalationPending   EscalationStatus = "pending"
+	EscalationDone      EscalationStatus = "done"
+	EscalationFailed    EscalationStatus = "failed"
+	EscalationCancelled EscalationStatus = "cancelled"
+)
+
+// PendingEscalation is the next step of the ladder scheduled for an incident.
+// Step is the index in EscalationPolicy.Steps.
+type PendingEscalation struct {
+	ID         int
+	IncidentID int
+	Step       int
+	DueAt      time.Time
+}
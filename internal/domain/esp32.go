@@ -1,9 +1,19 @@
 package domain
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoDeviceLinked is returned when a chat has no ESP32 linked to it.
+var ErrNoDeviceLinked = errors.New("No hay un ESP32 registrado para este chat")
+
 type ESP32 struct {
-	ID          int
-	Serial      string
-	NumeroSerie string 
+	ID               int
+	Serial           string
+	NumeroSerie      string
+	EmergencyContact string
+	Credentials      []DeviceCredential // claves usadas para firmar las alertas (HMAC), sin el secreto
 }
 
 type TelegramChat struct {
@@ -24,7 +34,11 @@ type ESP32Repository interface {
 	GetBySerial(serial string) (*ESP32, error)
 	LinkChatToESP32(chatID int64, serial string) error
 	GetLastKY026Reading(serial string) (*KY026Reading, error)
-	GetESP32SerialByChat(chatID int64) (string, error)
+	GetESP32SerialsByChat(chatID int64) ([]string, error)
+	UnlinkChatFromESP32(chatID int64, serial string) (bool, error)
 	GetChatsByESP32Serial(serial string) ([]int64, error)
 	GetUserByESP32Serial(serial string) (*User, error) 
+	// GetActiveCredentials returns the credentials valid at the given time
+	// with their secrets, which GetBySerial leaves out.
+	GetActiveCredentials(serial string, at time.Time) ([]DeviceCredential, error)
 }
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// FlapPolicy configures how the alerts of a sensor type are debounced.
+type FlapPolicy struct {
+	Debounce  time.Duration // a change closer than this to the previous one is a bounce
+	Window    time.Duration // period over which changes are counted
+	Threshold int           // changes within Window that mark the sensor as flapping; 0 disables it
+}
+
+// Enabled reports whether the policy debounces anything at all.
+func (p FlapPolicy) Enabled() bool {
+	return p.Debounce > 0 || p.Threshold > 0
+}
+
+// FlapState is what the flap detector decided about one alert.
+type FlapState struct {
+	Suppress bool // neither notify the alert nor close its incident
+	Flapping bool // the sensor is toggling faster than its policy allows
+	Settled  bool // a suppressed alert replayed once the sensor went quiet
+	// SettleAt is when a suppressed alert is replayed if its sensor stays
+	// quiet until then.
+	SettleAt time.Time
+}
+
+// SuppressReason explains in delivery reports why the alert was not notified.
+func (s FlapState) SuppressReason() string {
+	if s.Flapping {
+		return "sensor flapping"
+	}
+	return "debounced"
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidTransition = errors.New("invalid incident transition")
+
+type IncidentStatus string
+
+const (
+	IncidentOpen         IncidentStatus = "open"
+	IncidentAcknowledged IncidentStatus = "acknowledged"
+	IncidentResolved     IncidentStatus = "resolved"
+	IncidentFalseAlarm   IncidentStatus = "false_alarm"
+)
+
+// Closed reports whether no further transitions are possible.
+func (s IncidentStatus) Closed() bool {
+	return s == IncidentResolved || s == IncidentFalseAlarm
+}
+
+// CanTransitionTo implements open -> acknowledged -> resolved / false alarm.
+// An open incident can also be closed directly.
+func (s IncidentStatus) CanTransitionTo(next IncidentStatus) bool {
+	switch s {
+	case IncidentOpen:
+		return next == IncidentAcknowledged || next == IncidentResolved || next == IncidentFalseAlarm
+	case IncidentAcknowledged:
+		return next == IncidentResolved || next == IncidentFalseAlarm
+	default:
+		return false
+	}
+}
+
+// Incident groups the activation and deactivation alerts of a sensor.
+type Incident struct {
+	ID          int
+	ESP32Serial string
+	Sensor      string
+	Status      IncidentStatus
+	OpenedAt    time.Time
+	UpdatedAt   time.Time
+	ClosedAt    *time.Time
+	Flapping    bool // the sensor toggled repeatedly and its alerts were collapsed
+}
+
+// IncidentTransition records who moved an incident to a new state and when.
+type IncidentTransition struct {
+	ID         int
+	IncidentID int
+	FromStatus IncidentStatus
+	ToStatus   IncidentStatus
+	Actor      string
+	At         time.Time
+}
+
+// IncidentMessage is a Telegram message sent for an incident, kept so it can
+// be edited when the incident changes state.
+type IncidentMessage struct {
+	IncidentID int
+	ChatID     int64
+	MessageID  int
+	Text       string
+}
+
+// Transition moves the incident to next and returns the record to persist.
+func (i *Incident) Transition(next IncidentStatus, actor string, at time.Time) (IncidentTransition, error) {
+	if !i.Status.CanTransitionTo(next) {
+		return IncidentTransition{}, ErrInvalidTransition
+	}
+
+	transition := IncidentTransition{
+		IncidentID: i.ID,
+		FromStatus: i.Status,
+		ToStatus:   next,
+		Actor:      actor,
+		At:         at,
+	}
+	i.Status = next
+	i.UpdatedAt = at
+	if next.Closed() {
+		i.ClosedAt = &at
+	}
+	return transition, nil
+}
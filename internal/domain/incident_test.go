@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIncidentTransition(t *testing.T) {
+	opened := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := opened.Add(time.Minute)
+
+	tests := []struct {
+		from    IncidentStatus
+		to      IncidentStatus
+		wantErr bool
+		closed  bool
+	}{
+		{IncidentOpen, IncidentAcknowledged, false, false},
+		{IncidentOpen, IncidentResolved, false, true},
+		{IncidentOpen, IncidentFalseAlarm, false, true},
+		{IncidentOpen, IncidentOpen, true, false},
+		{IncidentAcknowledged, IncidentResolved, false, true},
+		{IncidentAcknowledged, IncidentFalseAlarm, false, true},
+		{IncidentAcknowledged, IncidentOpen, true, false},
+		{IncidentResolved, IncidentFalseAlarm, true, false},
+		{IncidentResolved, IncidentOpen, true, false},
+		{IncidentFalseAlarm, IncidentResolved, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			incident := Incident{ID: 7, Status: tt.from, OpenedAt: opened, UpdatedAt: opened}
+			transition, err := incident.Transition(tt.to, "chat:1", at)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("Transition() error = %v, want %v", err, ErrInvalidTransition)
+				}
+				if incident.Status != tt.from || !incident.UpdatedAt.Equal(opened) {
+					t.Fatalf("a rejected transition changed the incident: %+v", incident)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Transition() error = %v", err)
+			}
+
+			want := IncidentTransition{IncidentID: 7, FromStatus: tt.from, ToStatus: tt.to, Actor: "chat:1", At: at}
+			if transition != want {
+				t.Errorf("transition = %+v, want %+v", transition, want)
+			}
+			if incident.Status != tt.to || !incident.UpdatedAt.Equal(at) {
+				t.Errorf("incident = %+v, want status %s updated at %v", incident, tt.to, at)
+			}
+			if closed := incident.ClosedAt != nil; closed != tt.closed {
+				t.Errorf("ClosedAt set = %v, want %v", closed, tt.closed)
+			}
+		})
+	}
+}
@@ -1,7 +1,49 @@
 package domain
 
+// NotificationPreferences are the channels a user wants to be notified by.
+// Users without stored preferences get DefaultNotificationPreferences.
+//
+// EnableDeviceChats is a setting of the user's devices, not of a chat: users
+// have no Telegram chat of their own, so turning it off stops the alerts of
+// their devices in every chat linked to them, group chats included.
 type NotificationPreferences struct {
-    EnableTelegram bool
-    EnableEmail    bool
-    EnableSMS     bool
-}
\ No newline at end of file
+    EnableDeviceChats bool
+    EnableEmail       bool
+    EnableSMS         bool
+}
+
+var DefaultNotificationPreferences = NotificationPreferences{EnableDeviceChats: true}
+
+// EmailMessage is an email with a plain text and an HTML alternative.
+type EmailMessage struct {
+    To       string
+    Subject  string
+    TextBody string
+    HTMLBody string
+}
+
+// UserNotification is the message published to RabbitMQ for downstream
+// consumers when a device owned by a user sends an alert.
+type UserNotification struct {
+    UserID        int    `json:"user_id"`
+    Username      string `json:"username"`
+    Email         string `json:"email"`
+    SensorType    string `json:"sensor_type"`
+    Estado        int    `json:"estado"`
+    Activacion    string `json:"activacion"`
+    Desactivacion string `json:"desactivacion"`
+    NumeroSerie   string `json:"numero_serie"`
+}
+
+func NewUserNotification(user *User, alert *Alert) UserNotification {
+    return UserNotification{
+        UserID:        user.ID,
+        Username:      user.Username,
+        Email:         user.Email,
+        SensorType:    alert.Sensor,
+        Estado:        alert.Estado,
+        Activacion:    alert.FechaActivacion,
+        Desactivacion: alert.FechaDesactivacion,
+        NumeroSerie:   alert.NumeroSerie,
+    }
+}
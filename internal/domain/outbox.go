@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxMessage is a message waiting in MySQL to be published to RabbitMQ.
+// It is written in the same transaction as the reading that produced it.
+type OutboxMessage struct {
+	ID            int
+	RoutingKey    string
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+func NewOutboxMessage(routingKey string, payload interface{}) (OutboxMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	now := time.Now().UTC()
+	return OutboxMessage{RoutingKey: routingKey, Payload: body, NextAttemptAt: now, CreatedAt: now}, nil
+}
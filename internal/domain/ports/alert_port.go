@@ -4,4 +4,24 @@ import "telegramassist/internal/domain"
 
 type AlertNotifier interface {
     NotifyAlert(alert *domain.Alert) error
-}
\ No newline at end of file
+}
+
+// AlertManager stores the alerts accepted for asynchronous processing.
+type AlertManager interface {
+    // SaveAlert stores a new alert and returns true. When an alert with the
+    // same dedup key was already stored it loads that one into record and
+    // returns false.
+    SaveAlert(record *domain.AlertRecord) (bool, error)
+    // SaveAlertBatch is SaveAlert for several alerts, storing the new ones
+    // and their readings in a single transaction. outbox holds the messages
+    // of each record; those of the last new alert of each device are written
+    // with it and counted in its OutboxQueued.
+    SaveAlertBatch(records []*domain.AlertRecord, outbox [][]domain.OutboxMessage) ([]bool, error)
+    GetAlert(id int) (*domain.AlertRecord, error)
+    // ListQueuedAlerts returns the alerts still waiting to be processed,
+    // queued, processing or flapping pending past their SettleAt, oldest
+    // first.
+    ListQueuedAlerts(limit int) ([]domain.AlertRecord, error)
+    CheckpointAlerts(checkpoint domain.AlertCheckpoint) error
+    UpdateAlert(record *domain.AlertRecord) error
+}
@@ -1,9 +1,20 @@
 package ports
 
-import "telegramassist/internal/domain"
+import (
+    "time"
+
+    "telegramassist/internal/domain"
+)
 
 type DeviceManager interface {
     GetDevice(serial string) (*domain.ESP32, error)
     GetLinkedChats(serial string) ([]int64, error)
     LinkDeviceToChat(chatID int64, serial string) error
-}
\ No newline at end of file
+}
+
+type DeviceCredentialManager interface {
+    CreateCredential(credential *domain.DeviceCredential) error
+    ListCredentials(serial string) ([]domain.DeviceCredential, error)
+    ExpireCredentials(serial string, expiresAt time.Time) error
+    RevokeCredential(serial string, keyID string, revokedAt time.Time) (bool, error)
+}
@@ -0,0 +1,10 @@
+package ports
+
+import "telegramassist/internal/domain"
+
+type EscalationManager interface {
+    GetEscalationPolicy(serial string) (*domain.EscalationPolicy, error)
+    SaveEscalation(escalation *domain.PendingEscalation) error
+    ListPendingEscalations() ([]domain.PendingEscalation, error)
+    CompleteEscalation(id int, status domain.EscalationStatus) error
+}
@@ -0,0 +1,14 @@
+package ports
+
+import "telegramassist/internal/domain"
+
+type IncidentManager interface {
+    CreateIncident(incident *domain.Incident, actor string) error
+    GetIncident(id int) (*domain.Incident, error)
+    GetOpenIncident(serial string, sensor string) (*domain.Incident, error)
+    SaveTransition(incident *domain.Incident, transition domain.IncidentTransition) error
+    MarkIncidentFlapping(id int) error
+    ListTransitions(incidentID int) ([]domain.IncidentTransition, error)
+    SaveIncidentMessage(message domain.IncidentMessage) error
+    ListIncidentMessages(incidentID int) ([]domain.IncidentMessage, error)
+}
@@ -2,13 +2,34 @@ package ports
 
 import "telegramassist/internal/domain"
 
+// NotificationManager sends an alert through every notification channel and
+// reports the outcome of each one.
 type NotificationManager interface {
-    NotifyUsers(chatIDs []int64, alert *domain.Alert) error
+    NotifyAlert(notification domain.AlertNotification) (domain.DeliveryReport, error)
     GetNotificationPreferences(userID int) (NotificationPreferences, error)
 }
 
-type NotificationPreferences struct {
-    EnableTelegram bool
-    EnableEmail    bool
-    EnableSMS     bool
-}
\ No newline at end of file
+// NotificationChannel delivers an alert through one medium. Deliver reports
+// its own failures instead of returning them so one channel never stops the
+// others.
+type NotificationChannel interface {
+    Name() string
+    Deliver(notification domain.AlertNotification) domain.ChannelReport
+}
+
+// NotificationPreferences is kept as an alias so existing code using the
+// ports name keeps compiling; the type lives in the domain.
+type NotificationPreferences = domain.NotificationPreferences
+
+type NotificationPreferencesManager interface {
+    GetNotificationPreferences(userID int) (domain.NotificationPreferences, error)
+    SaveNotificationPreferences(userID int, preferences domain.NotificationPreferences) error
+}
+
+type EmailSender interface {
+    SendEmail(message domain.EmailMessage) error
+}
+
+type SMSSender interface {
+    SendSMS(to string, text string) error
+}
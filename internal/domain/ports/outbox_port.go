@@ -0,0 +1,23 @@
+package ports
+
+import (
+    "time"
+
+    "telegramassist/internal/domain"
+)
+
+type OutboxManager interface {
+    EnqueueOutbox(messages []domain.OutboxMessage) error
+    // ClaimPendingOutbox returns up to limit messages due for publishing and
+    // hides them from other relays for the lease duration.
+    ClaimPendingOutbox(limit int, lease time.Duration) ([]domain.OutboxMessage, error)
+    MarkOutboxSent(id int) error
+    // RescheduleOutbox records a failed attempt and when to try again.
+    RescheduleOutbox(id int, attempts int, nextAttemptAt time.Time, lastError string) error
+    // MarkOutboxFailed gives up on the message after its last attempt.
+    MarkOutboxFailed(id int, attempts int, lastError string) error
+}
+
+type MessagePublisher interface {
+    PublishMessage(routingKey string, body []byte) error
+}
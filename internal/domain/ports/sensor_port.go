@@ -5,5 +5,5 @@ import "telegramassist/internal/domain"
 type KY026Manager interface {
     GetLastReading(serial string) (*domain.KY026Reading, error)
     SaveReading(reading *domain.KY026Reading) error
-    ProcessAlert(alert *domain.Alert) error
+    ListReadings(query domain.ReadingQuery) (*domain.ReadingPage, error)
 }
\ No newline at end of file
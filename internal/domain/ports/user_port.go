@@ -0,0 +1,7 @@
+package ports
+
+import "telegramassist/internal/domain"
+
+type UserManager interface {
+    GetUser(id int) (*domain.User, error)
+}
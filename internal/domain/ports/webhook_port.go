@@ -0,0 +1,28 @@
+package ports
+
+import (
+    "time"
+
+    "telegramassist/internal/domain"
+)
+
+type WebhookManager interface {
+    CreateWebhook(subscription *domain.WebhookSubscription) error
+    GetWebhook(id int) (*domain.WebhookSubscription, error)
+    ListWebhooks() ([]domain.WebhookSubscription, error)
+    // ListWebhooksForDevice returns the active subscriptions of the device
+    // and of its owner.
+    ListWebhooksForDevice(serial string) ([]domain.WebhookSubscription, error)
+    SetWebhookActive(id int, active bool) error
+    DeleteWebhook(id int) error
+
+    CreateWebhookDeliveries(deliveries []domain.WebhookDelivery) error
+    // ClaimDueWebhookDeliveries returns up to limit pending deliveries of
+    // active subscriptions and hides them from other workers for the lease.
+    ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+    ListWebhookDeliveries(subscriptionID int, limit int) ([]domain.WebhookDelivery, error)
+    // RecordWebhookAttempt saves the outcome of a delivery attempt and
+    // updates the failure streak of its subscription, disabling it once the
+    // streak reaches disableAfter.
+    RecordWebhookAttempt(delivery *domain.WebhookDelivery, disableAfter int) error
+}
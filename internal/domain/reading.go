@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// FechaLayout is the format the ESP32 uses for fecha_activacion. Readings
+// are stored as text in this format, which sorts chronologically.
+const FechaLayout = "2006-01-02 15:04:05"
+
+// ReadingQuery selects the readings of a device. Zero From/To leave the range
+// open on that side; To is exclusive. Readings are returned newest first
+// unless Ascending is set. A non-zero Cursor continues after the reading with
+// that ID in the chosen order; it does not affect the totals.
+type ReadingQuery struct {
+	Serial    string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+	Cursor    int
+	Ascending bool
+}
+
+// ReadingPage is a page of readings with the totals of the whole range.
+type ReadingPage struct {
+	Readings    []KY026Reading
+	Total       int
+	Activations int
+}
@@ -0,0 +1,56 @@
+package domain
+
+import "strings"
+
+// Severity classifies an alert for routing and for choosing notification
+// channels.
+type Severity string
+
+const (
+	SeverityHigh   Severity = "high"   // a flame sensor activated
+	SeverityMedium Severity = "medium" // any other sensor activated
+	SeverityLow    Severity = "low"    // a sensor went back to normal
+)
+
+// flameSensors are the sensors whose activation means a possible fire.
+var flameSensors = map[string]bool{
+	"KY_026": true,
+}
+
+// AlertSeverity returns the severity of the alert.
+func AlertSeverity(alert *Alert) Severity {
+	switch {
+	case alert.Estado != 1:
+		return SeverityLow
+	case flameSensors[alert.Sensor]:
+		return SeverityHigh
+	default:
+		return SeverityMedium
+	}
+}
+
+// AlertRoutingKey is the topic exchange routing key of an alert:
+//
+//	alert.<sensor>.<severity>.<serial>
+//
+// e.g. "alert.ky_026.high.ESP32-001". Consumers bind with patterns such as
+// "alert.*.high.#" or "alert.ky_026.#". The sensor is lowercased and dots
+// and wildcards in the sensor or serial are replaced so they cannot add
+// words to the key.
+func AlertRoutingKey(alert *Alert) string {
+	return strings.Join([]string{
+		"alert",
+		routingWord(strings.ToLower(alert.Sensor)),
+		string(AlertSeverity(alert)),
+		routingWord(alert.NumeroSerie),
+	}, ".")
+}
+
+var routingWordReplacer = strings.NewReplacer(".", "_", "*", "_", "#", "_", " ", "_")
+
+func routingWord(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return routingWordReplacer.Replace(s)
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "testing"
+
+func TestAlertRoutingKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		alert    Alert
+		want     string
+		severity Severity
+	}{
+		{"flame sensor active", Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 1}, "alert.ky_026.high.ESP32-001", SeverityHigh},
+		{"flame sensor back to normal", Alert{NumeroSerie: "ESP32-001", Sensor: "KY_026", Estado: 0}, "alert.ky_026.low.ESP32-001", SeverityLow},
+		{"other sensor active", Alert{NumeroSerie: "ESP32-002", Sensor: "MQ2", Estado: 1}, "alert.mq2.medium.ESP32-002", SeverityMedium},
+		{"dots and wildcards", Alert{NumeroSerie: "ESP.#1", Sensor: "gas.*", Estado: 1}, "alert.gas__.medium.ESP__1", SeverityMedium},
+		{"spaces", Alert{NumeroSerie: "ESP 3", Sensor: "Flame Sensor", Estado: 0}, "alert.flame_sensor.low.ESP_3", SeverityLow},
+		{"empty words", Alert{Estado: 1}, "alert.unknown.medium.unknown", SeverityMedium},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AlertRoutingKey(&tt.alert); got != tt.want {
+				t.Errorf("AlertRoutingKey() = %q, want %q", got, tt.want)
+			}
+			if got := AlertSeverity(&tt.alert); got != tt.severity {
+				t.Errorf("AlertSeverity() = %q, want %q", got, tt.severity)
+			}
+		})
+	}
+}
@@ -8,5 +8,4 @@ type SensorReader interface {
 // KY026Reader specific interface for KY026 sensor
 type KY026Reader interface {
     GetLastReading(serial string) (*KY026Reading, error)
-    ProcessKY026Alert(alert *Alert) error
 }
\ No newline at end of file
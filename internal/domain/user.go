@@ -5,4 +5,5 @@ type User struct {
 	ID       int
 	Username string
 	Email    string
+	Phone    string // E.164, e.g. +5219611234567; empty when unknown
 }
\ No newline at end of file
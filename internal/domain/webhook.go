@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+type WebhookFormat string
+
+const (
+	WebhookFormatJSON  WebhookFormat = "json"  // the alert as JSON
+	WebhookFormatSlack WebhookFormat = "slack" // {"text": ...}, for Slack-compatible hooks
+)
+
+// WebhookSubscription is an HTTPS endpoint that receives the alerts of one
+// device (ESP32Serial) or of every device owned by a user (UserID).
+// Endpoints failing DisableAfter times in a row are disabled automatically.
+type WebhookSubscription struct {
+	ID                  int
+	ESP32Serial         string
+	UserID              int
+	URL                 string
+	Secret              string // clave HMAC con la que se firma cada entrega
+	Format              WebhookFormat
+	Active              bool
+	ConsecutiveFailures int
+	DisabledAt          *time.Time
+	CreatedAt           time.Time
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one alert sent to one subscription, kept as the
+// delivery log. The payload is rendered once so every retry sends the same
+// body.
+type WebhookDelivery struct {
+	ID             int
+	SubscriptionID int
+	Payload        []byte
+	Status         WebhookDeliveryStatus
+	Attempts       int
+	LastStatusCode int
+	LastError      string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
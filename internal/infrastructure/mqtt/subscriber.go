@@ -0,0 +1,195 @@
+package mqtt
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "telegramassist/internal/domain"
+
+    paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+    defaultTopic = "devices/+/alerts"
+    qos          = 1
+)
+
+var errSerialMismatch = errors.New("el numeroSerie del mensaje no coincide con el del topic")
+
+// ErrPoisonMessage marks alerts that can never be processed; the subscriber
+// acknowledges them instead of leaving them for redelivery.
+var ErrPoisonMessage = errors.New("mqtt: poison message")
+
+// AlertSubscriber receives the alerts ESP32 devices publish over MQTT to
+// devices/{serial}/alerts and hands them to process.
+//
+// Messages are QoS 1 and acknowledged once process succeeds or the message
+// turns out to be malformed or an ErrPoisonMessage. Other failures leave the
+// message unacknowledged so the broker delivers it again after reconnecting.
+// The session is persistent (clean session off) so the broker keeps the
+// alerts published while the service is down. Devices are not signed here: the broker ACLs
+// must only let each device publish to its own topic.
+type AlertSubscriber struct {
+    brokerURL string
+    topic     string
+    process   func(alert *domain.Alert) error
+
+    mu        sync.RWMutex
+    client    paho.Client
+    connected bool
+    lastError error
+}
+
+// NewAlertSubscriber reads MQTT_BROKER_URL (e.g. tcp://localhost:1883),
+// MQTT_CLIENT_ID, MQTT_USERNAME, MQTT_PASSWORD and MQTT_TOPIC.
+func NewAlertSubscriber(process func(alert *domain.Alert) error) *AlertSubscriber {
+    return NewAlertSubscriberFor(os.Getenv("MQTT_BROKER_URL"), envOr("MQTT_TOPIC", defaultTopic), process)
+}
+
+// NewAlertSubscriberFor subscribes to topic on the given broker, which
+// allows pointing it at an embedded broker.
+func NewAlertSubscriberFor(brokerURL string, topic string, process func(alert *domain.Alert) error) *AlertSubscriber {
+    return &AlertSubscriber{
+        brokerURL: brokerURL,
+        topic:     topic,
+        process:   process,
+    }
+}
+
+// Enabled reports whether a broker is configured.
+func (s *AlertSubscriber) Enabled() bool {
+    return s.brokerURL != ""
+}
+
+// Start connects in the background, retrying until the broker is reachable,
+// and subscribes again after every reconnection.
+func (s *AlertSubscriber) Start() {
+    opts := paho.NewClientOptions().
+        AddBroker(s.brokerURL).
+        SetClientID(envOr("MQTT_CLIENT_ID", "telegramassist")).
+        SetUsername(os.Getenv("MQTT_USERNAME")).
+        SetPassword(os.Getenv("MQTT_PASSWORD")).
+        SetCleanSession(false).
+        SetOrderMatters(true).
+        SetAutoAckDisabled(true).
+        SetAutoReconnect(true).
+        SetConnectRetry(true).
+        SetConnectRetryInterval(5 * time.Second).
+        SetMaxReconnectInterval(30 * time.Second).
+        SetOnConnectHandler(s.onConnect).
+        SetConnectionLostHandler(s.onConnectionLost)
+
+    client := paho.NewClient(opts)
+    s.mu.Lock()
+    s.client = client
+    s.mu.Unlock()
+
+    client.Connect()
+}
+
+// Stop disconnects, letting the message being processed finish.
+func (s *AlertSubscriber) Stop() {
+    s.mu.RLock()
+    client := s.client
+    s.mu.RUnlock()
+
+    if client != nil {
+        client.Disconnect(1000)
+    }
+}
+
+// Health implements the health check used by the /health endpoint.
+func (s *AlertSubscriber) Health() (string, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    if s.connected {
+        return "connected", true
+    }
+    if s.lastError != nil {
+        return fmt.Sprintf("disconnected: %v", s.lastError), false
+    }
+    return "connecting", false
+}
+
+func (s *AlertSubscriber) onConnect(client paho.Client) {
+    token := client.Subscribe(s.topic, qos, s.handle)
+    if token.Wait() && token.Error() != nil {
+        // Without the subscription no alert arrives, so the subscriber is not
+        // healthy until the next reconnection subscribes again.
+        log.Printf("Error subscribing to %s: %v", s.topic, token.Error())
+        s.setConnected(false, token.Error())
+        return
+    }
+    s.setConnected(true, nil)
+    log.Printf("Conectado a MQTT, suscrito a %s", s.topic)
+}
+
+func (s *AlertSubscriber) onConnectionLost(_ paho.Client, err error) {
+    s.setConnected(false, err)
+    log.Printf("MQTT connection lost: %v", err)
+}
+
+func (s *AlertSubscriber) setConnected(connected bool, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.connected = connected
+    s.lastError = err
+}
+
+func (s *AlertSubscriber) handle(_ paho.Client, msg paho.Message) {
+    alert, err := decodeAlert(msg.Topic(), msg.Payload())
+    if err != nil {
+        // Acknowledged anyway: redelivering a malformed message cannot help.
+        log.Printf("Discarding MQTT message from %s: %v", msg.Topic(), err)
+        msg.Ack()
+        return
+    }
+
+    err = s.process(alert)
+    switch {
+    case err == nil:
+        msg.Ack()
+    case errors.Is(err, ErrPoisonMessage):
+        log.Printf("Discarding MQTT alert from %s: %v", msg.Topic(), err)
+        msg.Ack()
+    default:
+        log.Printf("Error processing MQTT alert from %s, leaving it for redelivery: %v", msg.Topic(), err)
+    }
+}
+
+// decodeAlert reads the alert of a devices/{serial}/alerts message. The
+// serial comes from the topic; a payload naming a different device is
+// rejected.
+func decodeAlert(topic string, payload []byte) (*domain.Alert, error) {
+    parts := strings.Split(topic, "/")
+    if len(parts) != 3 || parts[1] == "" {
+        return nil, fmt.Errorf("unexpected topic %q", topic)
+    }
+    serial := parts[1]
+
+    var alert domain.Alert
+    if err := json.Unmarshal(payload, &alert); err != nil {
+        return nil, fmt.Errorf("error decoding alert: %v", err)
+    }
+    if alert.NumeroSerie == "" {
+        alert.NumeroSerie = serial
+    }
+    if alert.NumeroSerie != serial {
+        return nil, errSerialMismatch
+    }
+    return &alert, nil
+}
+
+func envOr(key, fallback string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return fallback
+}
@@ -0,0 +1,130 @@
+package mqtt
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "telegramassist/internal/domain"
+
+    mochi "github.com/mochi-mqtt/server/v2"
+    "github.com/mochi-mqtt/server/v2/hooks/auth"
+    "github.com/mochi-mqtt/server/v2/listeners"
+)
+
+func TestDecodeAlert(t *testing.T) {
+    tests := []struct {
+        name    string
+        topic   string
+        payload string
+        serial  string
+        wantErr error
+    }{
+        {"serial from topic", "devices/ESP32-001/alerts", `{"sensor":"KY_026","estado":1}`, "ESP32-001", nil},
+        {"matching serial", "devices/ESP32-001/alerts", `{"numeroSerie":"ESP32-001","sensor":"KY_026"}`, "ESP32-001", nil},
+        {"serial mismatch", "devices/ESP32-001/alerts", `{"numeroSerie":"ESP32-002","sensor":"KY_026"}`, "", errSerialMismatch},
+        {"empty serial in topic", "devices//alerts", `{"sensor":"KY_026"}`, "", errors.New("topic")},
+        {"unexpected topic", "devices/ESP32-001", `{"sensor":"KY_026"}`, "", errors.New("topic")},
+        {"invalid json", "devices/ESP32-001/alerts", `{`, "", errors.New("json")},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            alert, err := decodeAlert(tt.topic, []byte(tt.payload))
+            if tt.wantErr != nil {
+                if err == nil {
+                    t.Fatalf("decodeAlert() = %+v, want an error", alert)
+                }
+                if tt.wantErr == errSerialMismatch && !errors.Is(err, errSerialMismatch) {
+                    t.Fatalf("decodeAlert() error = %v, want %v", err, errSerialMismatch)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("decodeAlert() error = %v", err)
+            }
+            if alert.NumeroSerie != tt.serial {
+                t.Errorf("NumeroSerie = %q, want %q", alert.NumeroSerie, tt.serial)
+            }
+        })
+    }
+}
+
+// startBroker runs an in-process broker on a random local port and returns
+// its URL.
+func startBroker(t *testing.T) (*mochi.Server, string) {
+    t.Helper()
+
+    server := mochi.New(&mochi.Options{InlineClient: true})
+    if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+        t.Fatal(err)
+    }
+    tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+    if err := server.AddListener(tcp); err != nil {
+        t.Fatal(err)
+    }
+    if err := server.Serve(); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { server.Close() })
+
+    return server, "tcp://" + tcp.Address()
+}
+
+func TestAlertSubscriberReceivesAlerts(t *testing.T) {
+    broker, url := startBroker(t)
+
+    received := make(chan *domain.Alert, 10)
+    subscriber := NewAlertSubscriberFor(url, defaultTopic, func(alert *domain.Alert) error {
+        received <- alert
+        return nil
+    })
+    subscriber.Start()
+    t.Cleanup(subscriber.Stop)
+
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        if _, ok := subscriber.Health(); ok {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatal("subscriber did not connect")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    publish := func(topic, payload string) {
+        t.Helper()
+        if err := broker.Publish(topic, []byte(payload), false, qos); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // The mismatching message is dropped, so the next alert received is the
+    // one published after it.
+    publish("devices/ESP32-001/alerts", `{"sensor":"KY_026","estado":1}`)
+    publish("devices/ESP32-002/alerts", `{"numeroSerie":"ESP32-003","sensor":"KY_026","estado":1}`)
+    publish("devices/ESP32-002/alerts", `{"sensor":"KY_026","estado":0}`)
+
+    for _, want := range []struct {
+        serial string
+        estado int
+    }{
+        {"ESP32-001", 1},
+        {"ESP32-002", 0},
+    } {
+        select {
+        case alert := <-received:
+            if alert.NumeroSerie != want.serial || alert.Estado != want.estado {
+                t.Errorf("received %s estado %d, want %s estado %d", alert.NumeroSerie, alert.Estado, want.serial, want.estado)
+            }
+        case <-time.After(5 * time.Second):
+            t.Fatalf("alert of %s not received", want.serial)
+        }
+    }
+
+    select {
+    case alert := <-received:
+        t.Errorf("unexpected alert %+v", alert)
+    case <-time.After(100 * time.Millisecond):
+    }
+}
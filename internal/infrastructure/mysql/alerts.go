@@ -0,0 +1,227 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	driver "github.com/go-sql-driver/mysql"
+
+	"telegramassist/internal/domain"
+)
+
+// errDuplicateEntry is the MySQL error number for unique key violations.
+const errDuplicateEntry = 1062
+
+const alertColumns = `id, COALESCE(event_id, ''), numero_serie, sensor, estado, fecha_activacion, fecha_desactivacion,
+	status, attempts, COALESCE(incident_id, 0), delivery, COALESCE(last_error, ''), received_at, processed_at, reading_stored, settle_at, outbox_queued,
+	notify_pending, incident_opened`
+
+func scanAlert(row interface{ Scan(...interface{}) error }) (domain.AlertRecord, error) {
+	var a domain.AlertRecord
+	var status string
+	var delivery []byte
+	var processedAt, settleAt sql.NullTime
+	err := row.Scan(&a.ID, &a.Alert.EventID, &a.Alert.NumeroSerie, &a.Alert.Sensor, &a.Alert.Estado,
+		&a.Alert.FechaActivacion, &a.Alert.FechaDesactivacion,
+		&status, &a.Attempts, &a.IncidentID, &delivery, &a.LastError, &a.ReceivedAt, &processedAt, &a.ReadingStored, &settleAt, &a.OutboxQueued,
+		&a.NotifyPending, &a.IncidentOpened)
+	if err != nil {
+		return a, err
+	}
+	a.Status = domain.AlertStatus(status)
+	if processedAt.Valid {
+		a.ProcessedAt = &processedAt.Time
+	}
+	if settleAt.Valid {
+		a.SettleAt = &settleAt.Time
+	}
+	if len(delivery) > 0 {
+		a.Delivery = &domain.DeliveryReport{}
+		if err := json.Unmarshal(delivery, a.Delivery); err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+type queryExecer interface {
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertAlert stores the record, or loads the one with the same dedup key
+// into it and returns false.
+func insertAlert(db queryExecer, record *domain.AlertRecord) (bool, error) {
+	dedupKey := record.Alert.DedupKey()
+	result, err := db.Exec(`
+		INSERT INTO alerts (event_id, dedup_key, numero_serie, sensor, estado, fecha_activacion, fecha_desactivacion, status, attempts, received_at, reading_stored)
+		VALUES (NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, 0, ?, ?)`,
+		record.Alert.EventID, dedupKey, record.Alert.NumeroSerie, record.Alert.Sensor, record.Alert.Estado,
+		record.Alert.FechaActivacion, record.Alert.FechaDesactivacion, record.Status, record.ReceivedAt, record.ReadingStored)
+	var mysqlErr *driver.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry {
+		existing, err := scanAlert(db.QueryRow("SELECT "+alertColumns+" FROM alerts WHERE dedup_key = ?", dedupKey))
+		if err != nil {
+			return false, err
+		}
+		*record = existing
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	record.ID = int(id)
+	return true, nil
+}
+
+// Implement AlertManager interface
+func (r *MySQLRepository) SaveAlert(record *domain.AlertRecord) (bool, error) {
+	return insertAlert(r.db, record)
+}
+
+// SaveAlertBatch relies on a duplicate key only failing its own statement,
+// so the rest of the batch is still committed.
+func (r *MySQLRepository) SaveAlertBatch(records []*domain.AlertRecord, outbox [][]domain.OutboxMessage) ([]bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created := make([]bool, len(records))
+	last := make(map[string]int)
+	var serials []string
+	for i, record := range records {
+		record.ReadingStored = true
+		if created[i], err = insertAlert(tx, record); err != nil {
+			return nil, err
+		}
+		if !created[i] {
+			continue
+		}
+		if err := insertReading(tx, &record.Alert); err != nil {
+			return nil, err
+		}
+		serial := record.Alert.NumeroSerie
+		if _, ok := last[serial]; !ok {
+			serials = append(serials, serial)
+		}
+		last[serial] = i
+	}
+
+	for _, serial := range serials {
+		record := records[last[serial]]
+		if err := insertOutbox(tx, outbox[last[serial]]); err != nil {
+			return nil, err
+		}
+		record.OutboxQueued = len(outbox[last[serial]])
+		if _, err := tx.Exec("UPDATE alerts SET outbox_queued = ? WHERE id = ?", record.OutboxQueued, record.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *MySQLRepository) GetAlert(id int) (*domain.AlertRecord, error) {
+	a, err := scanAlert(r.db.QueryRow("SELECT "+alertColumns+" FROM alerts WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *MySQLRepository) ListQueuedAlerts(limit int) ([]domain.AlertRecord, error) {
+	rows, err := r.db.Query(
+		"SELECT "+alertColumns+` FROM alerts
+		WHERE status IN (?, ?) OR (status = ? AND (settle_at IS NULL OR settle_at <= UTC_TIMESTAMP()))
+		ORDER BY id LIMIT ?`,
+		domain.AlertQueued, domain.AlertProcessing, domain.AlertFlappingPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []domain.AlertRecord
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, a)
+	}
+	return records, rows.Err()
+}
+
+func (r *MySQLRepository) CheckpointAlerts(checkpoint domain.AlertCheckpoint) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if checkpoint.Reading != nil {
+		if err := insertReading(tx, checkpoint.Reading); err != nil {
+			return err
+		}
+	}
+	if err := insertOutbox(tx, checkpoint.Outbox); err != nil {
+		return err
+	}
+
+	for _, record := range checkpoint.Records {
+		status := domain.AlertProcessing
+		if record.SettleAt != nil {
+			status = domain.AlertFlappingPending
+		}
+		_, err := tx.Exec(`
+			UPDATE alerts
+			SET status = ?, incident_id = NULLIF(?, 0), settle_at = ?, outbox_queued = ?, notify_pending = ?, incident_opened = ?
+			WHERE id = ?`,
+			status, checkpoint.IncidentID, record.SettleAt, record.OutboxQueued, record.NotifyPending, record.IncidentOpened, record.ID)
+		if err != nil {
+			return err
+		}
+		// An older suppressed alert of the sensor must not be replayed
+		// after this one.
+		_, err = tx.Exec(`
+			UPDATE alerts held
+			JOIN alerts newer ON newer.id = ? AND held.numero_serie = newer.numero_serie AND held.sensor = newer.sensor
+			SET held.status = ?
+			WHERE held.status = ? AND held.id < newer.id`,
+			record.ID, domain.AlertProcessed, domain.AlertFlappingPending)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *MySQLRepository) UpdateAlert(record *domain.AlertRecord) error {
+	var delivery []byte
+	if record.Delivery != nil {
+		var err error
+		if delivery, err = json.Marshal(record.Delivery); err != nil {
+			return err
+		}
+	}
+	_, err := r.db.Exec(`
+		UPDATE alerts
+		SET status = ?, attempts = ?, incident_id = NULLIF(?, 0), delivery = ?, last_error = NULLIF(?, ''), processed_at = ?, settle_at = ?,
+			notify_pending = ?
+		WHERE id = ?`,
+		record.Status, record.Attempts, record.IncidentID, delivery, record.LastError, record.ProcessedAt, record.SettleAt,
+		record.NotifyPending, record.ID)
+	return err
+}
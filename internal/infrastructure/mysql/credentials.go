@@ -0,0 +1,93 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"telegramassist/internal/domain"
+)
+
+// Implement DeviceCredentialManager interface
+func (r *MySQLRepository) CreateCredential(credential *domain.DeviceCredential) error {
+	result, err := r.db.Exec(
+		"INSERT INTO esp32_credentials (esp32_serial, key_id, secret, created_at) VALUES (?, ?, ?, ?)",
+		credential.ESP32Serial, credential.KeyID, credential.Secret, credential.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	credential.ID = int(id)
+	return nil
+}
+
+// ListCredentials returns the metadata of the credentials of the device; the
+// secrets are left empty.
+func (r *MySQLRepository) ListCredentials(serial string) ([]domain.DeviceCredential, error) {
+	return r.queryCredentials(`
+		SELECT id, esp32_serial, key_id, '', created_at, expires_at, revoked_at
+		FROM esp32_credentials
+		WHERE esp32_serial = ?
+		ORDER BY id`, serial)
+}
+
+// GetActiveCredentials returns the credentials the device may sign with at
+// the given time, secrets included. Only signature verification needs them.
+func (r *MySQLRepository) GetActiveCredentials(serial string, at time.Time) ([]domain.DeviceCredential, error) {
+	return r.queryCredentials(`
+		SELECT id, esp32_serial, key_id, secret, created_at, expires_at, revoked_at
+		FROM esp32_credentials
+		WHERE esp32_serial = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY id`, serial, at)
+}
+
+func (r *MySQLRepository) queryCredentials(query string, args ...interface{}) ([]domain.DeviceCredential, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credentials []domain.DeviceCredential
+	for rows.Next() {
+		var c domain.DeviceCredential
+		var expiresAt, revokedAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.ESP32Serial, &c.KeyID, &c.Secret, &c.CreatedAt, &expiresAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			c.ExpiresAt = &expiresAt.Time
+		}
+		if revokedAt.Valid {
+			c.RevokedAt = &revokedAt.Time
+		}
+		credentials = append(credentials, c)
+	}
+	return credentials, rows.Err()
+}
+
+// ExpireCredentials schedules the expiry of every live credential of the
+// device, keeping an earlier expiry if one was already set.
+func (r *MySQLRepository) ExpireCredentials(serial string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE esp32_credentials
+		SET expires_at = ?
+		WHERE esp32_serial = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`,
+		expiresAt, serial, expiresAt)
+	return err
+}
+
+func (r *MySQLRepository) RevokeCredential(serial string, keyID string, revokedAt time.Time) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE esp32_credentials SET revoked_at = ? WHERE esp32_serial = ? AND key_id = ? AND revoked_at IS NULL",
+		revokedAt, serial, keyID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
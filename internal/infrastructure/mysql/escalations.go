@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"telegramassist/internal/domain"
+)
+
+// Implement EscalationManager interface
+func (r *MySQLRepository) GetEscalationPolicy(serial string) (*domain.EscalationPolicy, error) {
+	policy := &domain.EscalationPolicy{ESP32Serial: serial}
+	var renotify, secondary, webhook sql.NullInt64
+	var webhookURL sql.NullString
+	err := r.db.QueryRow(`
+		SELECT renotify_minutes, secondary_minutes, webhook_minutes, webhook_url
+		FROM escalation_policies
+		WHERE esp32_serial = ?`, serial).
+		Scan(&renotify, &secondary, &webhook, &webhookURL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policy.RenotifyAfter = time.Duration(renotify.Int64) * time.Minute
+	policy.SecondaryAfter = time.Duration(secondary.Int64) * time.Minute
+	policy.WebhookAfter = time.Duration(webhook.Int64) * time.Minute
+	policy.WebhookURL = webhookURL.String
+
+	rows, err := r.db.Query("SELECT chat_id FROM escalation_contacts WHERE esp32_serial = ?", serial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
+		}
+		policy.SecondaryChatIDs = append(policy.SecondaryChatIDs, chatID)
+	}
+	return policy, rows.Err()
+}
+
+func (r *MySQLRepository) SaveEscalation(escalation *domain.PendingEscalation) error {
+	result, err := r.db.Exec(
+		"INSERT INTO incident_escalations (incident_id, step, due_at, status) VALUES (?, ?, ?, ?)",
+		escalation.IncidentID, escalation.Step, escalation.DueAt, domain.EscalationPending)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	escalation.ID = int(id)
+	return nil
+}
+
+func (r *MySQLRepository) ListPendingEscalations() ([]domain.PendingEscalation, error) {
+	rows, err := r.db.Query(
+		"SELECT id, incident_id, step, due_at FROM incident_escalations WHERE status = ? ORDER BY due_at",
+		domain.EscalationPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var escalations []domain.PendingEscalation
+	for rows.Next() {
+		var e domain.PendingEscalation
+		if err := rows.Scan(&e.ID, &e.IncidentID, &e.Step, &e.DueAt); err != nil {
+			return nil, err
+		}
+		escalations = append(escalations, e)
+	}
+	return escalations, rows.Err()
+}
+
+func (r *MySQLRepository) CompleteEscalation(id int, status domain.EscalationStatus) error {
+	_, err := r.db.Exec(
+		"UPDATE incident_escalations SET status = ?, executed_at = ? WHERE id = ?",
+		status, time.Now().UTC(), id)
+	return err
+}
@@ -0,0 +1,156 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"telegramassist/internal/domain"
+)
+
+const incidentColumns = "id, esp32_serial, sensor, status, opened_at, updated_at, closed_at, flapping"
+
+// Implement IncidentManager interface
+func (r *MySQLRepository) CreateIncident(incident *domain.Incident, actor string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO incidents (esp32_serial, sensor, status, opened_at, updated_at, flapping) VALUES (?, ?, ?, ?, ?, ?)",
+		incident.ESP32Serial, incident.Sensor, incident.Status, incident.OpenedAt, incident.UpdatedAt, incident.Flapping)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO incident_transitions (incident_id, from_status, to_status, actor, created_at) VALUES (?, NULL, ?, ?, ?)",
+		id, incident.Status, actor, incident.OpenedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	incident.ID = int(id)
+	return nil
+}
+
+func (r *MySQLRepository) GetIncident(id int) (*domain.Incident, error) {
+	row := r.db.QueryRow("SELECT "+incidentColumns+" FROM incidents WHERE id = ?", id)
+	return scanIncident(row)
+}
+
+func (r *MySQLRepository) GetOpenIncident(serial string, sensor string) (*domain.Incident, error) {
+	row := r.db.QueryRow(`
+		SELECT `+incidentColumns+`
+		FROM incidents
+		WHERE esp32_serial = ? AND sensor = ? AND closed_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1`, serial, sensor)
+	return scanIncident(row)
+}
+
+func (r *MySQLRepository) MarkIncidentFlapping(id int) error {
+	_, err := r.db.Exec("UPDATE incidents SET flapping = TRUE WHERE id = ?", id)
+	return err
+}
+
+// SaveTransition updates the incident status and records the transition in
+// the same transaction.
+func (r *MySQLRepository) SaveTransition(incident *domain.Incident, transition domain.IncidentTransition) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE incidents SET status = ?, updated_at = ?, closed_at = ? WHERE id = ?",
+		incident.Status, incident.UpdatedAt, incident.ClosedAt, incident.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO incident_transitions (incident_id, from_status, to_status, actor, created_at) VALUES (?, ?, ?, ?, ?)",
+		transition.IncidentID, transition.FromStatus, transition.ToStatus, transition.Actor, transition.At)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *MySQLRepository) ListTransitions(incidentID int) ([]domain.IncidentTransition, error) {
+	rows, err := r.db.Query(`
+		SELECT id, incident_id, from_status, to_status, actor, created_at
+		FROM incident_transitions
+		WHERE incident_id = ?
+		ORDER BY id`, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []domain.IncidentTransition
+	for rows.Next() {
+		var t domain.IncidentTransition
+		var from sql.NullString
+		if err := rows.Scan(&t.ID, &t.IncidentID, &from, &t.ToStatus, &t.Actor, &t.At); err != nil {
+			return nil, err
+		}
+		t.FromStatus = domain.IncidentStatus(from.String)
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+func (r *MySQLRepository) SaveIncidentMessage(message domain.IncidentMessage) error {
+	_, err := r.db.Exec(
+		"INSERT INTO incident_messages (incident_id, chat_id, message_id, text) VALUES (?, ?, ?, ?)",
+		message.IncidentID, message.ChatID, message.MessageID, message.Text)
+	return err
+}
+
+func (r *MySQLRepository) ListIncidentMessages(incidentID int) ([]domain.IncidentMessage, error) {
+	rows, err := r.db.Query(
+		"SELECT incident_id, chat_id, message_id, text FROM incident_messages WHERE incident_id = ? ORDER BY id",
+		incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []domain.IncidentMessage
+	for rows.Next() {
+		var m domain.IncidentMessage
+		if err := rows.Scan(&m.IncidentID, &m.ChatID, &m.MessageID, &m.Text); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+func scanIncident(row *sql.Row) (*domain.Incident, error) {
+	incident := &domain.Incident{}
+	var closedAt sql.NullTime
+	err := row.Scan(&incident.ID, &incident.ESP32Serial, &incident.Sensor, &incident.Status,
+		&incident.OpenedAt, &incident.UpdatedAt, &closedAt, &incident.Flapping)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if closedAt.Valid {
+		incident.ClosedAt = &closedAt.Time
+	}
+	return incident, nil
+}
@@ -16,7 +16,7 @@ type MySQLRepository struct {
 }
 
 func NewMySQLRepository() (*MySQLRepository, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
 		os.Getenv("DB_USER"),
 		os.Getenv("DB_PASSWORD"),
 		os.Getenv("DB_HOST"),
@@ -39,11 +39,18 @@ func NewMySQLRepository() (*MySQLRepository, error) {
 
 func (r *MySQLRepository) GetBySerial(serial string) (*domain.ESP32, error) {
 	esp := &domain.ESP32{}
-	err := r.db.QueryRow("SELECT idESP32, numero_serie FROM ESP32 WHERE numero_serie = ?", serial).
-		Scan(&esp.ID, &esp.Serial) 
+	var emergencyContact sql.NullString
+	err := r.db.QueryRow("SELECT idESP32, numero_serie, emergency_contact FROM ESP32 WHERE numero_serie = ?", serial).
+		Scan(&esp.ID, &esp.Serial, &emergencyContact)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	esp.EmergencyContact = emergencyContact.String
+
+	esp.Credentials, err = r.ListCredentials(serial)
 	return esp, err
 }
 
@@ -71,15 +78,32 @@ func (r *MySQLRepository) GetLastKY026Reading(serial string) (*domain.KY026Readi
 	return reading, err
 }
 
-// Update the GetESP32SerialByChat method
-func (r *MySQLRepository) GetESP32SerialByChat(chatID int64) (string, error) {
-	var serial string
-	err := r.db.QueryRow("SELECT esp32_serial FROM telegram_chats WHERE chat_id = ? ORDER BY created_at DESC LIMIT 1", chatID).
-		Scan(&serial)
-	if err == sql.ErrNoRows {
-		return "", nil
+func (r *MySQLRepository) GetESP32SerialsByChat(chatID int64) ([]string, error) {
+	rows, err := r.db.Query("SELECT esp32_serial FROM telegram_chats WHERE chat_id = ? ORDER BY created_at", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []string
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, err
+		}
+		serials = append(serials, serial)
+	}
+	return serials, rows.Err()
+}
+
+func (r *MySQLRepository) UnlinkChatFromESP32(chatID int64, serial string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM telegram_chats WHERE chat_id = ? AND esp32_serial = ?", chatID, serial)
+	if err != nil {
+		return false, err
 	}
-	return serial, err
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
 }
 
 
@@ -138,8 +162,8 @@ func (r *MySQLRepository) GetUserByESP32Serial(serial string) (*domain.User, err
 	
 	// Then, get the user details
 	user := &domain.User{}
-	err = r.db.QueryRow("SELECT id, username, email FROM users WHERE id = ?", userID.Int64).
-		Scan(&user.ID, &user.Username, &user.Email)
+	err = r.db.QueryRow("SELECT id, username, email, COALESCE(phone, '') FROM users WHERE id = ?", userID.Int64).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Phone)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No user found
@@ -173,22 +197,16 @@ func (r *MySQLRepository) SaveReading(reading *domain.KY026Reading) error {
     return err
 }
 
-func (r *MySQLRepository) ProcessAlert(alert *domain.Alert) error {
+// insertReading stores the reading of the alerts of sensors that keep their
+// own table; the other alerts have nothing to store.
+func insertReading(db execer, alert *domain.Alert) error {
     if alert.Sensor != "KY_026" {
         return nil
     }
-    reading := &domain.KY026Reading{
-        ESP32Serial:     alert.NumeroSerie,
-        FechaActivacion: alert.FechaActivacion,
-        Estado:          strconv.Itoa(alert.Estado),
-    }
-    return r.SaveReading(reading)
-}
-
-// Add these methods to implement NotificationManager interface
-func (r *MySQLRepository) NotifyUsers(chatIDs []int64, alert *domain.Alert) error {
-    // Implement notification logic here
-    return nil
+    _, err := db.Exec(
+        "INSERT INTO KY_026 (numero_serie, fecha_activacion, estado) VALUES (?, ?, ?)",
+        alert.NumeroSerie, alert.FechaActivacion, strconv.Itoa(alert.Estado))
+    return err
 }
 
 func (r *MySQLRepository) GetLinkedChats(serial string) ([]int64, error) {
@@ -204,12 +222,3 @@ func (r *MySQLRepository) GetDevice(serial string) (*domain.ESP32, error) {
 func (r *MySQLRepository) LinkDeviceToChat(chatID int64, serial string) error {
     return r.LinkChatToESP32(chatID, serial)
 }
-
-func (r *MySQLRepository) GetNotificationPreferences(userID int) (domain.NotificationPreferences, error) {
-    // Default preferences for now
-    return domain.NotificationPreferences{
-        EnableTelegram: true,
-        EnableEmail:    false,
-        EnableSMS:      false,
-    }, nil
-}
@@ -0,0 +1,119 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"telegramassist/internal/domain"
+)
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertOutbox(db execer, messages []domain.OutboxMessage) error {
+	for _, m := range messages {
+		_, err := db.Exec(
+			"INSERT INTO notification_outbox (routing_key, payload, status, attempts, next_attempt_at, created_at) VALUES (?, ?, 'pending', 0, ?, ?)",
+			m.RoutingKey, m.Payload, m.NextAttemptAt, m.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Implement OutboxManager interface
+func (r *MySQLRepository) EnqueueOutbox(messages []domain.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertOutbox(tx, messages); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClaimPendingOutbox locks the due rows with SKIP LOCKED (MySQL 8) and pushes
+// their next attempt past the lease, so several relays never publish the
+// same message at the same time.
+func (r *MySQLRepository) ClaimPendingOutbox(limit int, lease time.Duration) ([]domain.OutboxMessage, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	rows, err := tx.Query(`
+		SELECT id, routing_key, payload, attempts, next_attempt_at, COALESCE(last_error, ''), created_at
+		FROM notification_outbox
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []domain.OutboxMessage
+	for rows.Next() {
+		var m domain.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.RoutingKey, &m.Payload, &m.Attempts, &m.NextAttemptAt, &m.LastError, &m.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(messages))
+	args := []interface{}{now.Add(lease)}
+	for i, m := range messages {
+		placeholders[i] = "?"
+		args = append(args, m.ID)
+	}
+	_, err = tx.Exec(
+		"UPDATE notification_outbox SET next_attempt_at = ? WHERE id IN ("+strings.Join(placeholders, ", ")+")",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, tx.Commit()
+}
+
+func (r *MySQLRepository) MarkOutboxSent(id int) error {
+	_, err := r.db.Exec(
+		"UPDATE notification_outbox SET status = 'sent', sent_at = ? WHERE id = ?",
+		time.Now().UTC(), id)
+	return err
+}
+
+func (r *MySQLRepository) RescheduleOutbox(id int, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.Exec(
+		"UPDATE notification_outbox SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?",
+		attempts, nextAttemptAt, lastError, id)
+	return err
+}
+
+func (r *MySQLRepository) MarkOutboxFailed(id int, attempts int, lastError string) error {
+	_, err := r.db.Exec(
+		"UPDATE notification_outbox SET status = 'failed', attempts = ?, last_error = ? WHERE id = ?",
+		attempts, lastError, id)
+	return err
+}
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"telegramassist/internal/domain"
+)
+
+// Implement NotificationPreferencesManager interface
+func (r *MySQLRepository) GetNotificationPreferences(userID int) (domain.NotificationPreferences, error) {
+	var p domain.NotificationPreferences
+	err := r.db.QueryRow(`
+		SELECT enable_device_chats, enable_email, enable_sms
+		FROM notification_preferences
+		WHERE user_id = ?`, userID).
+		Scan(&p.EnableDeviceChats, &p.EnableEmail, &p.EnableSMS)
+	if err == sql.ErrNoRows {
+		return domain.DefaultNotificationPreferences, nil
+	}
+	return p, err
+}
+
+func (r *MySQLRepository) SaveNotificationPreferences(userID int, p domain.NotificationPreferences) error {
+	_, err := r.db.Exec(`
+		INSERT INTO notification_preferences (user_id, enable_device_chats, enable_email, enable_sms, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			enable_device_chats = VALUES(enable_device_chats),
+			enable_email = VALUES(enable_email),
+			enable_sms = VALUES(enable_sms),
+			updated_at = VALUES(updated_at)`,
+		userID, p.EnableDeviceChats, p.EnableEmail, p.EnableSMS, time.Now().UTC())
+	return err
+}
+
+func (r *MySQLRepository) GetUser(id int) (*domain.User, error) {
+	user := &domain.User{}
+	err := r.db.QueryRow("SELECT id, username, email, COALESCE(phone, '') FROM users WHERE id = ?", id).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Phone)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
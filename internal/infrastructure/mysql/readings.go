@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"strings"
+	"time"
+
+	"telegramassist/internal/domain"
+)
+
+// readingFilter builds the WHERE clause shared by the reading queries.
+// fecha_activacion is stored as text in domain.FechaLayout so the range is
+// compared as strings.
+func readingFilter(query domain.ReadingQuery) (string, []interface{}) {
+	conditions := []string{"numero_serie = ?"}
+	args := []interface{}{query.Serial}
+	if !query.From.IsZero() {
+		conditions = append(conditions, "fecha_activacion >= ?")
+		args = append(args, query.From.In(time.Local).Format(domain.FechaLayout))
+	}
+	if !query.To.IsZero() {
+		conditions = append(conditions, "fecha_activacion < ?")
+		args = append(args, query.To.In(time.Local).Format(domain.FechaLayout))
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *MySQLRepository) ListReadings(query domain.ReadingQuery) (*domain.ReadingPage, error) {
+	where, args := readingFilter(query)
+	page := &domain.ReadingPage{}
+
+	err := r.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(estado = '1'), 0) FROM KY_026"+where, args...).
+		Scan(&page.Total, &page.Activations)
+	if err != nil {
+		return nil, err
+	}
+
+	order, cursorOp := "DESC", "<"
+	if query.Ascending {
+		order, cursorOp = "ASC", ">"
+	}
+	if query.Cursor > 0 {
+		where += " AND idKY_026 " + cursorOp + " ?"
+		args = append(args, query.Cursor)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT idKY_026, numero_serie, fecha_activacion, estado
+		FROM KY_026`+where+`
+		ORDER BY idKY_026 `+order+`
+		LIMIT ? OFFSET ?`, append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reading domain.KY026Reading
+		if err := rows.Scan(&reading.ID, &reading.ESP32Serial, &reading.FechaActivacion, &reading.Estado); err != nil {
+			return nil, err
+		}
+		page.Readings = append(page.Readings, reading)
+	}
+	return page, rows.Err()
+}
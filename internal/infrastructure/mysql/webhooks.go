@@ -0,0 +1,267 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"telegramassist/internal/domain"
+)
+
+const webhookColumns = `id, COALESCE(esp32_serial, ''), COALESCE(user_id, 0), url, secret, format,
+	active, consecutive_failures, disabled_at, created_at`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (domain.WebhookSubscription, error) {
+	var w domain.WebhookSubscription
+	var format string
+	var disabledAt sql.NullTime
+	err := row.Scan(&w.ID, &w.ESP32Serial, &w.UserID, &w.URL, &w.Secret, &format,
+		&w.Active, &w.ConsecutiveFailures, &disabledAt, &w.CreatedAt)
+	w.Format = domain.WebhookFormat(format)
+	if disabledAt.Valid {
+		w.DisabledAt = &disabledAt.Time
+	}
+	return w, err
+}
+
+// Implement WebhookManager interface
+func (r *MySQLRepository) CreateWebhook(subscription *domain.WebhookSubscription) error {
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_subscriptions (esp32_serial, user_id, url, secret, format, active, created_at)
+		VALUES (NULLIF(?, ''), NULLIF(?, 0), ?, ?, ?, TRUE, ?)`,
+		subscription.ESP32Serial, subscription.UserID, subscription.URL, subscription.Secret,
+		subscription.Format, subscription.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	subscription.ID = int(id)
+	subscription.Active = true
+	return nil
+}
+
+func (r *MySQLRepository) GetWebhook(id int) (*domain.WebhookSubscription, error) {
+	w, err := scanWebhook(r.db.QueryRow("SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *MySQLRepository) ListWebhooks() ([]domain.WebhookSubscription, error) {
+	return r.queryWebhooks("SELECT " + webhookColumns + " FROM webhook_subscriptions ORDER BY id")
+}
+
+func (r *MySQLRepository) ListWebhooksForDevice(serial string) ([]domain.WebhookSubscription, error) {
+	return r.queryWebhooks(`
+		SELECT `+webhookColumns+`
+		FROM webhook_subscriptions
+		WHERE active AND (esp32_serial = ?
+			OR user_id = (SELECT idUser FROM ESP32 WHERE numero_serie = ?))
+		ORDER BY id`, serial, serial)
+}
+
+func (r *MySQLRepository) queryWebhooks(query string, args ...interface{}) ([]domain.WebhookSubscription, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []domain.WebhookSubscription
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, w)
+	}
+	return subscriptions, rows.Err()
+}
+
+func (r *MySQLRepository) SetWebhookActive(id int, active bool) error {
+	if active {
+		_, err := r.db.Exec(
+			"UPDATE webhook_subscriptions SET active = TRUE, consecutive_failures = 0, disabled_at = NULL WHERE id = ?", id)
+		return err
+	}
+	_, err := r.db.Exec(
+		"UPDATE webhook_subscriptions SET active = FALSE, disabled_at = ? WHERE id = ?", time.Now().UTC(), id)
+	return err
+}
+
+func (r *MySQLRepository) DeleteWebhook(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE subscription_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_subscriptions WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *MySQLRepository) CreateWebhookDeliveries(deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, d := range deliveries {
+		_, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (subscription_id, payload, status, attempts, next_attempt_at, created_at)
+			VALUES (?, ?, ?, 0, ?, ?)`,
+			d.SubscriptionID, d.Payload, domain.WebhookDeliveryPending, d.NextAttemptAt, d.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const webhookDeliveryColumns = `d.id, d.subscription_id, d.payload, d.status, d.attempts,
+	COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.next_attempt_at, d.created_at, d.delivered_at`
+
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (domain.WebhookDelivery, error) {
+	var d domain.WebhookDelivery
+	var status string
+	var deliveredAt sql.NullTime
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.Payload, &status, &d.Attempts,
+		&d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &deliveredAt)
+	d.Status = domain.WebhookDeliveryStatus(status)
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, err
+}
+
+// ClaimDueWebhookDeliveries uses the same SKIP LOCKED lease as the
+// notification outbox.
+func (r *MySQLRepository) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	rows, err := tx.Query(`
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active
+		ORDER BY d.id
+		LIMIT ?
+		FOR UPDATE OF d SKIP LOCKED`, domain.WebhookDeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []domain.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(deliveries))
+	args := []interface{}{now.Add(lease)}
+	for i, d := range deliveries {
+		placeholders[i] = "?"
+		args = append(args, d.ID)
+	}
+	_, err = tx.Exec(
+		"UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN ("+strings.Join(placeholders, ", ")+")",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, tx.Commit()
+}
+
+func (r *MySQLRepository) ListWebhookDeliveries(subscriptionID int, limit int) ([]domain.WebhookDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.subscription_id = ?
+		ORDER BY d.id DESC
+		LIMIT ?`, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []domain.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *MySQLRepository) RecordWebhookAttempt(delivery *domain.WebhookDelivery, disableAfter int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, last_status_code = NULLIF(?, 0), last_error = NULLIF(?, ''),
+			next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError,
+		delivery.NextAttemptAt, delivery.DeliveredAt, delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.Status == domain.WebhookDeliveryDelivered {
+		_, err = tx.Exec("UPDATE webhook_subscriptions SET consecutive_failures = 0 WHERE id = ?", delivery.SubscriptionID)
+	} else {
+		// MySQL applies the assignments left to right, so active and
+		// disabled_at see the incremented streak.
+		_, err = tx.Exec(`
+			UPDATE webhook_subscriptions
+			SET consecutive_failures = consecutive_failures + 1,
+				active = consecutive_failures < ?,
+				disabled_at = IF(active, disabled_at, COALESCE(disabled_at, ?))
+			WHERE id = ?`, disableAfter, time.Now().UTC(), delivery.SubscriptionID)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,275 @@
+package rabbitmq
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "time"
+
+    "telegramassist/internal/domain"
+
+    "github.com/streadway/amqp"
+)
+
+const (
+    // requeueDelay keeps a message that failed for a transient reason from
+    // being redelivered in a tight loop.
+    requeueDelay = time.Second
+
+    // attemptsHeader counts the failed attempts to process a message.
+    attemptsHeader = "x-attempts"
+)
+
+// ErrPoisonMessage marks alerts that can never be processed (undecodable or
+// invalid); the consumer dead-letters them instead of requeueing.
+var ErrPoisonMessage = errors.New("rabbitmq: poison message")
+
+// AlertConsumer reads domain.Alert JSON messages from RABBITMQ_INPUT_QUEUE
+// and hands them to process, one at a time so the alerts of a device keep
+// their order. It uses its own connection so a blocked publisher never stalls
+// consumption, and resumes consuming after reconnecting.
+//
+// Messages are acked after process returns. Undecodable messages and errors
+// wrapping ErrPoisonMessage are dead-lettered to "<queue>.dlq" right away.
+// After other errors the message is published again to the queue with its
+// x-attempts header incremented, and dead-lettered once it has failed
+// RABBITMQ_MAX_ATTEMPTS times. The original is acked only once the broker
+// confirms the new copy, and requeued as it was otherwise. Messages requeued
+// because a consumer stopped do not count as attempts.
+type AlertConsumer struct {
+    url            string
+    queueName      string
+    prefetch       int
+    maxAttempts    int
+    confirmTimeout time.Duration
+    process        func(alert *domain.Alert) error
+
+    done chan struct{}
+}
+
+func NewAlertConsumer(process func(alert *domain.Alert) error) *AlertConsumer {
+    prefetch, err := strconv.Atoi(os.Getenv("RABBITMQ_PREFETCH"))
+    if err != nil || prefetch <= 0 {
+        prefetch = 10
+    }
+
+    maxAttempts, err := strconv.Atoi(os.Getenv("RABBITMQ_MAX_ATTEMPTS"))
+    if err != nil || maxAttempts <= 0 {
+        maxAttempts = 5
+    }
+    confirmSeconds, err := strconv.Atoi(os.Getenv("RABBITMQ_CONFIRM_TIMEOUT"))
+    if err != nil || confirmSeconds <= 0 {
+        confirmSeconds = 5
+    }
+
+    return &AlertConsumer{
+        url:            os.Getenv("RABBITMQ_URL"),
+        queueName:      os.Getenv("RABBITMQ_INPUT_QUEUE"),
+        prefetch:       prefetch,
+        maxAttempts:    maxAttempts,
+        confirmTimeout: time.Duration(confirmSeconds) * time.Second,
+        process:        process,
+        done:           make(chan struct{}),
+    }
+}
+
+// Enabled reports whether an input queue is configured.
+func (c *AlertConsumer) Enabled() bool {
+    return c.queueName != ""
+}
+
+// Start consumes in the background until ctx is cancelled. Wait returns once
+// the message being processed has been acked and the connection closed.
+func (c *AlertConsumer) Start(ctx context.Context) {
+    go func() {
+        defer close(c.done)
+        c.run(ctx)
+    }()
+}
+
+func (c *AlertConsumer) Wait() {
+    <-c.done
+}
+
+func (c *AlertConsumer) run(ctx context.Context) {
+    backoff := minBackoff
+    for {
+        err := c.consume(ctx)
+        if ctx.Err() != nil {
+            return
+        }
+        log.Printf("RabbitMQ consumer of %s stopped, retrying in %v: %v", c.queueName, backoff, err)
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(jitter(backoff)):
+        }
+        if backoff *= 2; backoff > maxBackoff {
+            backoff = maxBackoff
+        }
+    }
+}
+
+// consume runs one connection until it drops or ctx is cancelled.
+func (c *AlertConsumer) consume(ctx context.Context) error {
+    conn, err := amqp.Dial(c.url)
+    if err != nil {
+        return fmt.Errorf("failed to connect to RabbitMQ: %v", err)
+    }
+    defer conn.Close()
+
+    ch, err := conn.Channel()
+    if err != nil {
+        return fmt.Errorf("failed to open channel: %v", err)
+    }
+    if err := c.declareTopology(ch); err != nil {
+        return err
+    }
+    if err := ch.Qos(c.prefetch, 0, false); err != nil {
+        return fmt.Errorf("failed to set prefetch: %v", err)
+    }
+    // Retries are republished on this channel; messages are handled one at
+    // a time, so the next confirm belongs to the last retry.
+    if err := ch.Confirm(false); err != nil {
+        return fmt.Errorf("failed to enable publisher confirms: %v", err)
+    }
+    confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+    deliveries, err := ch.Consume(c.queueName, "", false, false, false, false, nil)
+    if err != nil {
+        return fmt.Errorf("failed to consume %s: %v", c.queueName, err)
+    }
+    log.Printf("Consumiendo alertas de RabbitMQ (%s)", c.queueName)
+
+    for {
+        select {
+        case <-ctx.Done():
+            // Unacked prefetched messages go back to the queue when the
+            // channel closes.
+            return nil
+        case delivery, ok := <-deliveries:
+            if !ok {
+                return ErrNotConnected
+            }
+            if err := c.handle(ch, confirms, delivery); err != nil {
+                return err
+            }
+        }
+    }
+}
+
+// declareTopology declares the durable input queue, dead-lettering through
+// the default exchange into "<queue>.dlq".
+func (c *AlertConsumer) declareTopology(ch *amqp.Channel) error {
+    dlq := c.queueName + ".dlq"
+    if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+        return fmt.Errorf("failed to declare dead-letter queue: %v", err)
+    }
+
+    args := amqp.Table{
+        "x-dead-letter-exchange":    "",
+        "x-dead-letter-routing-key": dlq,
+    }
+    if _, err := ch.QueueDeclare(c.queueName, true, false, false, false, args); err != nil {
+        return fmt.Errorf("failed to declare queue %s: %v", c.queueName, err)
+    }
+    return nil
+}
+
+// handle processes and acknowledges one message. It fails only when the
+// channel can no longer be used.
+func (c *AlertConsumer) handle(ch *amqp.Channel, confirms <-chan amqp.Confirmation, delivery amqp.Delivery) error {
+    var alert domain.Alert
+    err := json.Unmarshal(delivery.Body, &alert)
+    if err != nil {
+        err = fmt.Errorf("%w: %v", ErrPoisonMessage, err)
+    } else {
+        err = c.process(&alert)
+    }
+
+    attempts := deliveryAttempts(delivery) + 1
+    switch {
+    case err == nil:
+        err = delivery.Ack(false)
+    case errors.Is(err, ErrPoisonMessage):
+        log.Printf("Dead-lettering alert message from %s: %v", c.queueName, err)
+        err = delivery.Nack(false, false)
+    case attempts >= c.maxAttempts:
+        log.Printf("Dead-lettering alert message from %s after %d attempts: %v", c.queueName, attempts, err)
+        err = delivery.Nack(false, false)
+    default:
+        log.Printf("Retrying alert message from %s (attempt %d): %v", c.queueName, attempts, err)
+        time.Sleep(requeueDelay)
+        if err = c.retry(ch, confirms, delivery, attempts); errors.Is(err, ErrConfirmTimeout) {
+            return err
+        }
+    }
+    if err != nil {
+        log.Printf("Error acknowledging alert message: %v", err)
+    }
+    return nil
+}
+
+// retry publishes the message again with its attempt count and acks the
+// original once the broker confirms it. If the publish fails or is nacked
+// the original is requeued as it was. After ErrConfirmTimeout a late confirm
+// would be mistaken for the next retry's, so the channel must be dropped.
+func (c *AlertConsumer) retry(ch *amqp.Channel, confirms <-chan amqp.Confirmation, delivery amqp.Delivery, attempts int) error {
+    headers := amqp.Table{}
+    for k, v := range delivery.Headers {
+        headers[k] = v
+    }
+    headers[attemptsHeader] = int32(attempts)
+
+    err := ch.Publish("", c.queueName, false, false, amqp.Publishing{
+        Headers:      headers,
+        ContentType:  delivery.ContentType,
+        DeliveryMode: amqp.Persistent,
+        MessageId:    delivery.MessageId,
+        Timestamp:    delivery.Timestamp,
+        Body:         delivery.Body,
+    })
+    if err != nil {
+        log.Printf("Error republishing alert message to %s: %v", c.queueName, err)
+        return delivery.Nack(false, true)
+    }
+
+    timer := time.NewTimer(c.confirmTimeout)
+    defer timer.Stop()
+    select {
+    case confirm, ok := <-confirms:
+        if !ok {
+            return ErrNotConnected
+        }
+        if !confirm.Ack {
+            log.Printf("Republished alert message to %s was nacked by the broker", c.queueName)
+            return delivery.Nack(false, true)
+        }
+        return delivery.Ack(false)
+    case <-timer.C:
+        log.Printf("No confirm for the alert message republished to %s", c.queueName)
+        if err := delivery.Nack(false, true); err != nil {
+            log.Printf("Error acknowledging alert message: %v", err)
+        }
+        return ErrConfirmTimeout
+    }
+}
+
+// deliveryAttempts returns the failed attempts recorded on the message.
+func deliveryAttempts(delivery amqp.Delivery) int {
+    switch v := delivery.Headers[attemptsHeader].(type) {
+    case int32:
+        return int(v)
+    case int64:
+        return int(v)
+    case int:
+        return v
+    default:
+        return 0
+    }
+}
@@ -2,45 +2,430 @@ package rabbitmq
 
 import (
     "encoding/json"
+    "errors"
     "fmt"
+    "log"
+    "math/rand"
     "os"
+    "strconv"
+    "sync"
+    "time"
+
     "github.com/streadway/amqp"
 )
 
+type ConnectionState string
+
+const (
+    StateConnecting   ConnectionState = "connecting"
+    StateConnected    ConnectionState = "connected"
+    StateReconnecting ConnectionState = "reconnecting"
+    StateClosed       ConnectionState = "closed"
+)
+
+const (
+    minBackoff     = 500 * time.Millisecond
+    maxBackoff     = 30 * time.Second
+    acquireTimeout = 5 * time.Second
+)
+
+var (
+    ErrNotConnected   = errors.New("rabbitmq: not connected")
+    ErrPoolTimeout    = errors.New("rabbitmq: timed out waiting for a channel")
+    ErrClosed         = errors.New("rabbitmq: service closed")
+    ErrConfirmTimeout = errors.New("rabbitmq: timed out waiting for publisher confirm")
+    ErrNacked         = errors.New("rabbitmq: message nacked by broker")
+    ErrUnroutable     = errors.New("rabbitmq: message returned as unroutable")
+)
+
+// PublishError is returned by PublishMessage when a message could not be
+// confirmed by the broker. Err is one of the errors above or the underlying
+// AMQP error; Retryable tells whether publishing again may succeed.
+type PublishError struct {
+    Err       error
+    Retryable bool
+}
+
+func (e *PublishError) Error() string {
+    return e.Err.Error()
+}
+
+func (e *PublishError) Unwrap() error {
+    return e.Err
+}
+
+// Temporary reports whether publishing again may succeed.
+func (e *PublishError) Temporary() bool {
+    return e.Retryable
+}
+
+// pooledChannel is an AMQP channel in confirm mode together with the
+// notifications of its closure, confirms and returns. A channel is used by
+// one publisher at a time, so the next confirm belongs to its message.
+type pooledChannel struct {
+    ch       *amqp.Channel
+    closed   chan *amqp.Error
+    confirms chan amqp.Confirmation
+    returns  chan amqp.Return
+    // broken is set when a confirm did not arrive in time; a late confirm
+    // would be mistaken for the next message's, so the channel is discarded.
+    broken bool
+}
+
+func (p *pooledChannel) alive() bool {
+    if p.broken {
+        return false
+    }
+    select {
+    case <-p.closed:
+        return false
+    default:
+        return true
+    }
+}
+
+// RabbitMQService keeps a long-lived connection to the broker and a pool of
+// channels shared by concurrent publishers. When the connection drops it
+// reconnects in the background with exponential backoff.
 type RabbitMQService struct {
-    url       string
-    queueName string
+    url                string
+    exchangeName       string
+    queueName          string
+    bindingKey         string
+    deadLetterExchange string
+    poolSize           int
+    confirmTimeout     time.Duration
+
+    mu        sync.RWMutex
+    conn      *amqp.Connection
+    pool      chan *pooledChannel
+    state     ConnectionState
+    lastError error
+
+    done      chan struct{}
+    closeOnce sync.Once
 }
 
 func NewRabbitMQService() *RabbitMQService {
+    poolSize, err := strconv.Atoi(os.Getenv("RABBITMQ_CHANNEL_POOL"))
+    if err != nil || poolSize <= 0 {
+        poolSize = 4
+    }
+    confirmSeconds, err := strconv.Atoi(os.Getenv("RABBITMQ_CONFIRM_TIMEOUT"))
+    if err != nil || confirmSeconds <= 0 {
+        confirmSeconds = 5
+    }
+
     return &RabbitMQService{
-        url:       os.Getenv("RABBITMQ_URL"),
-        queueName: os.Getenv("RABBITMQ_QUEUE"),
+        url:                os.Getenv("RABBITMQ_URL"),
+        exchangeName:       envOr("RABBITMQ_EXCHANGE", "alerts"),
+        queueName:          os.Getenv("RABBITMQ_QUEUE"),
+        bindingKey:         envOr("RABBITMQ_BINDING_KEY", "alert.#"),
+        deadLetterExchange: os.Getenv("RABBITMQ_DLX"),
+        poolSize:           poolSize,
+        confirmTimeout:     time.Duration(confirmSeconds) * time.Second,
+        state:              StateConnecting,
+        done:               make(chan struct{}),
+    }
+}
+
+// Start connects to the broker in the background and keeps the connection
+// alive until Close is called.
+func (s *RabbitMQService) Start() {
+    go s.run()
+}
+
+// Close stops reconnecting and closes the connection.
+func (s *RabbitMQService) Close() error {
+    var err error
+    s.closeOnce.Do(func() {
+        close(s.done)
+
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        s.state = StateClosed
+        if s.conn != nil {
+            err = s.conn.Close()
+        }
+    })
+    return err
+}
+
+// State returns the connection state and the last connection error.
+func (s *RabbitMQService) State() (ConnectionState, error) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.state, s.lastError
+}
+
+// Health implements the health check used by the /health endpoint.
+func (s *RabbitMQService) Health() (string, bool) {
+    state, err := s.State()
+    if err != nil && state != StateConnected {
+        return fmt.Sprintf("%s: %v", state, err), false
     }
+    return string(state), state == StateConnected
 }
 
-func (s *RabbitMQService) PublishNotification(notification interface{}) error {
+func (s *RabbitMQService) run() {
+    backoff := minBackoff
+    for {
+        conn, err := s.connect()
+        if err != nil {
+            s.setState(StateReconnecting, err)
+            log.Printf("Error connecting to RabbitMQ, retrying in %v: %v", backoff, err)
+
+            select {
+            case <-s.done:
+                return
+            case <-time.After(jitter(backoff)):
+            }
+            if backoff *= 2; backoff > maxBackoff {
+                backoff = maxBackoff
+            }
+            continue
+        }
+
+        backoff = minBackoff
+        log.Println("Conectado a RabbitMQ")
+
+        closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+        select {
+        case <-s.done:
+            return
+        case amqpErr := <-closed:
+            var err error = ErrNotConnected
+            if amqpErr != nil {
+                err = amqpErr
+            }
+            s.setState(StateReconnecting, err)
+            log.Printf("RabbitMQ connection lost: %v", err)
+        }
+    }
+}
+
+// connect dials the broker, declares the topology and fills a fresh channel
+// pool.
+func (s *RabbitMQService) connect() (*amqp.Connection, error) {
     conn, err := amqp.Dial(s.url)
     if err != nil {
-        return fmt.Errorf("failed to connect to RabbitMQ: %v", err)
+        return nil, fmt.Errorf("failed to connect to RabbitMQ: %v", err)
     }
-    defer conn.Close()
 
+    if err := s.declareTopology(conn); err != nil {
+        conn.Close()
+        return nil, err
+    }
+
+    pool := make(chan *pooledChannel, s.poolSize)
+    for i := 0; i < s.poolSize; i++ {
+        ch, err := openChannel(conn)
+        if err != nil {
+            conn.Close()
+            return nil, err
+        }
+        pool <- ch
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    select {
+    case <-s.done:
+        conn.Close()
+        return nil, ErrClosed
+    default:
+    }
+    s.conn = conn
+    s.pool = pool
+    s.state = StateConnected
+    s.lastError = nil
+    return conn, nil
+}
+
+// declareTopology declares the durable topic exchange alerts are published
+// to and the notification queue, bound with RABBITMQ_BINDING_KEY (every alert
+// by default). When RABBITMQ_DLX is set it also declares a fanout dead-letter
+// exchange with a "<queue>.dlq" queue bound to it. Declaring an existing
+// queue with different arguments fails, so changing RABBITMQ_DLX requires
+// recreating the queue. Other consumers declare and bind their own queues.
+func (s *RabbitMQService) declareTopology(conn *amqp.Connection) error {
     ch, err := conn.Channel()
     if err != nil {
         return fmt.Errorf("failed to open channel: %v", err)
     }
     defer ch.Close()
 
+    if err := ch.ExchangeDeclare(s.exchangeName, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+        return fmt.Errorf("failed to declare exchange %s: %v", s.exchangeName, err)
+    }
+
+    var args amqp.Table
+    if s.deadLetterExchange != "" {
+        if err := ch.ExchangeDeclare(s.deadLetterExchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+            return fmt.Errorf("failed to declare dead-letter exchange: %v", err)
+        }
+        dlq := s.queueName + ".dlq"
+        if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+            return fmt.Errorf("failed to declare dead-letter queue: %v", err)
+        }
+        if err := ch.QueueBind(dlq, "", s.deadLetterExchange, false, nil); err != nil {
+            return fmt.Errorf("failed to bind dead-letter queue: %v", err)
+        }
+        args = amqp.Table{"x-dead-letter-exchange": s.deadLetterExchange}
+    }
+
+    if _, err := ch.QueueDeclare(s.queueName, true, false, false, false, args); err != nil {
+        return fmt.Errorf("failed to declare queue %s: %v", s.queueName, err)
+    }
+    if err := ch.QueueBind(s.queueName, s.bindingKey, s.exchangeName, false, nil); err != nil {
+        return fmt.Errorf("failed to bind queue %s: %v", s.queueName, err)
+    }
+    return nil
+}
+
+func openChannel(conn *amqp.Connection) (*pooledChannel, error) {
+    ch, err := conn.Channel()
+    if err != nil {
+        return nil, fmt.Errorf("failed to open channel: %v", err)
+    }
+    if err := ch.Confirm(false); err != nil {
+        ch.Close()
+        return nil, fmt.Errorf("failed to enable publisher confirms: %v", err)
+    }
+
+    return &pooledChannel{
+        ch:       ch,
+        closed:   ch.NotifyClose(make(chan *amqp.Error, 1)),
+        confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+        returns:  ch.NotifyReturn(make(chan amqp.Return, 1)),
+    }, nil
+}
+
+func (s *RabbitMQService) setState(state ConnectionState, err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.state == StateClosed {
+        return
+    }
+    s.state = state
+    s.lastError = err
+}
+
+// acquire takes a channel from the pool of the current connection.
+func (s *RabbitMQService) acquire() (*pooledChannel, chan *pooledChannel, error) {
+    s.mu.RLock()
+    state, pool := s.state, s.pool
+    s.mu.RUnlock()
+
+    if state == StateClosed {
+        return nil, nil, ErrClosed
+    }
+    if state != StateConnected || pool == nil {
+        return nil, nil, ErrNotConnected
+    }
+
+    select {
+    case ch := <-pool:
+        return ch, pool, nil
+    case <-time.After(acquireTimeout):
+        return nil, nil, ErrPoolTimeout
+    }
+}
+
+// release gives the channel back to its pool, replacing it first if it was
+// closed by the broker. Channels of a previous connection are dropped.
+func (s *RabbitMQService) release(ch *pooledChannel, pool chan *pooledChannel) {
+    s.mu.RLock()
+    conn, current := s.conn, s.pool
+    s.mu.RUnlock()
+
+    if pool != current {
+        return
+    }
+    if !ch.alive() {
+        ch.ch.Close()
+        replacement, err := openChannel(conn)
+        if err != nil {
+            log.Printf("Error replacing RabbitMQ channel: %v", err)
+            // Keep the pool size; the dead channel fails fast and is
+            // replaced again on the next release.
+            pool <- ch
+            return
+        }
+        ch = replacement
+    }
+    pool <- ch
+}
+
+// PublishNotification publishes the notification as a persistent JSON message
+// to the alerts exchange with the given routing key (see
+// domain.AlertRoutingKey) and waits for the broker to confirm it. Failures
+// are returned as *PublishError. It is safe for concurrent use.
+func (s *RabbitMQService) PublishNotification(routingKey string, notification interface{}) error {
     body, err := json.Marshal(notification)
     if err != nil {
         return fmt.Errorf("failed to marshal notification: %v", err)
     }
+    return s.PublishMessage(routingKey, body)
+}
+
+// PublishMessage publishes an already encoded JSON message, as stored by the
+// outbox, and waits for the broker to confirm it.
+func (s *RabbitMQService) PublishMessage(routingKey string, body []byte) error {
+    ch, pool, err := s.acquire()
+    if err != nil {
+        return &PublishError{Err: err, Retryable: err != ErrClosed}
+    }
+    defer s.release(ch, pool)
 
-    err = ch.Publish("", s.queueName, false, false,
+    // mandatory: a key no queue is bound to comes back as ErrUnroutable.
+    err = ch.ch.Publish(s.exchangeName, routingKey, true, false,
         amqp.Publishing{
-            ContentType: "application/json",
-            Body:        body,
+            ContentType:  "application/json",
+            DeliveryMode: amqp.Persistent,
+            Body:         body,
         })
-    return err
-}
\ No newline at end of file
+    if err != nil {
+        return &PublishError{Err: err, Retryable: true}
+    }
+
+    return s.waitConfirm(ch)
+}
+
+// waitConfirm waits for the confirm of the last message published on ch.
+// A basic.return, if any, arrives before the confirm.
+func (s *RabbitMQService) waitConfirm(ch *pooledChannel) error {
+    timer := time.NewTimer(s.confirmTimeout)
+    defer timer.Stop()
+
+    select {
+    case confirm, ok := <-ch.confirms:
+        if !ok {
+            return &PublishError{Err: ErrNotConnected, Retryable: true}
+        }
+        select {
+        case <-ch.returns:
+            return &PublishError{Err: ErrUnroutable}
+        default:
+        }
+        if !confirm.Ack {
+            return &PublishError{Err: ErrNacked, Retryable: true}
+        }
+        return nil
+    case <-timer.C:
+        ch.broken = true
+        return &PublishError{Err: ErrConfirmTimeout, Retryable: true}
+    }
+}
+
+func envOr(key, fallback string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return fallback
+}
+
+// jitter spreads reconnection attempts of several instances.
+func jitter(d time.Duration) time.Duration {
+    return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,38 @@
+package sms
+
+import "sync"
+
+// Message is an SMS recorded by FakeSender.
+type Message struct {
+    To   string
+    Text string
+}
+
+// FakeSender records the SMS instead of sending them, for tests and local
+// development. Err, when set, is returned by every send.
+type FakeSender struct {
+    mu   sync.Mutex
+    sent []Message
+    Err  error
+}
+
+func NewFakeSender() *FakeSender {
+    return &FakeSender{}
+}
+
+func (s *FakeSender) SendSMS(to string, text string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.Err != nil {
+        return s.Err
+    }
+    s.sent = append(s.sent, Message{To: to, Text: text})
+    return nil
+}
+
+// Sent returns a copy of the messages sent so far.
+func (s *FakeSender) Sent() []Message {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]Message(nil), s.sent...)
+}
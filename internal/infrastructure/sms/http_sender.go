@@ -0,0 +1,95 @@
+package sms
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+)
+
+// HTTPSender sends SMS through an HTTP provider. The request format is set
+// with SMS_PROVIDER_FORMAT:
+//
+//   json (default)  POST {"to": ..., "from": ..., "message": ...}
+//   form            POST To=...&From=...&Body=... (Twilio style)
+//
+// SMS_PROVIDER_TOKEN is sent as a Bearer token; SMS_PROVIDER_USERNAME and
+// SMS_PROVIDER_PASSWORD as basic auth, for providers using account SIDs.
+type HTTPSender struct {
+    endpoint   string
+    format     string
+    from       string
+    token      string
+    username   string
+    password   string
+    httpClient *http.Client
+}
+
+// NewHTTPSender reads SMS_PROVIDER_URL, SMS_PROVIDER_FORMAT, SMS_FROM and the
+// credentials above.
+func NewHTTPSender() *HTTPSender {
+    format := os.Getenv("SMS_PROVIDER_FORMAT")
+    if format == "" {
+        format = "json"
+    }
+    return &HTTPSender{
+        endpoint:   os.Getenv("SMS_PROVIDER_URL"),
+        format:     format,
+        from:       os.Getenv("SMS_FROM"),
+        token:      os.Getenv("SMS_PROVIDER_TOKEN"),
+        username:   os.Getenv("SMS_PROVIDER_USERNAME"),
+        password:   os.Getenv("SMS_PROVIDER_PASSWORD"),
+        httpClient: &http.Client{Timeout: 10 * time.Second},
+    }
+}
+
+// Enabled reports whether a provider is configured.
+func (s *HTTPSender) Enabled() bool {
+    return s.endpoint != ""
+}
+
+func (s *HTTPSender) SendSMS(to string, text string) error {
+    var body []byte
+    var contentType string
+    switch s.format {
+    case "json":
+        b, err := json.Marshal(map[string]string{"to": to, "from": s.from, "message": text})
+        if err != nil {
+            return err
+        }
+        body, contentType = b, "application/json"
+    case "form":
+        form := url.Values{"To": {to}, "From": {s.from}, "Body": {text}}
+        body, contentType = []byte(form.Encode()), "application/x-www-form-urlencoded"
+    default:
+        return fmt.Errorf("unknown SMS_PROVIDER_FORMAT %q", s.format)
+    }
+
+    req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", contentType)
+    if s.token != "" {
+        req.Header.Set("Authorization", "Bearer "+s.token)
+    } else if s.username != "" {
+        req.SetBasicAuth(s.username, s.password)
+    }
+
+    resp, err := s.httpClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("SMS provider responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+    }
+    return nil
+}
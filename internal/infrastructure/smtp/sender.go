@@ -0,0 +1,149 @@
+package smtp
+
+import (
+    "bytes"
+    "crypto/rand"
+    "crypto/tls"
+    "encoding/hex"
+    "fmt"
+    "mime"
+    "mime/quotedprintable"
+    "net"
+    "net/smtp"
+    "os"
+    "strings"
+    "time"
+
+    "telegramassist/internal/domain"
+)
+
+const dialTimeout = 10 * time.Second
+
+// Sender delivers emails through an SMTP server. It upgrades the connection
+// with STARTTLS when the server offers it and authenticates only when a
+// username is configured, so it also works against a local SMTP sink such as
+// MailHog (SMTP_HOST=localhost SMTP_PORT=1025).
+type Sender struct {
+    host     string
+    port     string
+    username string
+    password string
+    from     string
+}
+
+// NewSender reads SMTP_HOST, SMTP_PORT (587 by default), SMTP_USERNAME,
+// SMTP_PASSWORD and SMTP_FROM.
+func NewSender() *Sender {
+    port := os.Getenv("SMTP_PORT")
+    if port == "" {
+        port = "587"
+    }
+    return &Sender{
+        host:     os.Getenv("SMTP_HOST"),
+        port:     port,
+        username: os.Getenv("SMTP_USERNAME"),
+        password: os.Getenv("SMTP_PASSWORD"),
+        from:     os.Getenv("SMTP_FROM"),
+    }
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (s *Sender) Enabled() bool {
+    return s.host != "" && s.from != ""
+}
+
+func (s *Sender) SendEmail(message domain.EmailMessage) error {
+    body, err := s.compose(message)
+    if err != nil {
+        return err
+    }
+
+    conn, err := net.DialTimeout("tcp", net.JoinHostPort(s.host, s.port), dialTimeout)
+    if err != nil {
+        return fmt.Errorf("failed to connect to SMTP server: %v", err)
+    }
+    // The whole conversation shares the deadline so a stuck server cannot
+    // block the caller forever.
+    conn.SetDeadline(time.Now().Add(time.Minute))
+
+    client, err := smtp.NewClient(conn, s.host)
+    if err != nil {
+        conn.Close()
+        return err
+    }
+    defer client.Close()
+
+    if ok, _ := client.Extension("STARTTLS"); ok {
+        if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+            return fmt.Errorf("STARTTLS failed: %v", err)
+        }
+    }
+    if s.username != "" {
+        if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+            return fmt.Errorf("SMTP authentication failed: %v", err)
+        }
+    }
+
+    if err := client.Mail(s.from); err != nil {
+        return err
+    }
+    if err := client.Rcpt(message.To); err != nil {
+        return err
+    }
+    w, err := client.Data()
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(body); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return client.Quit()
+}
+
+// compose builds a multipart/alternative message with the text and HTML
+// bodies, both quoted-printable encoded.
+func (s *Sender) compose(message domain.EmailMessage) ([]byte, error) {
+    if strings.ContainsAny(message.To, "\r\n") {
+        return nil, fmt.Errorf("invalid recipient %q", message.To)
+    }
+    boundary, err := randomBoundary()
+    if err != nil {
+        return nil, err
+    }
+
+    var b bytes.Buffer
+    fmt.Fprintf(&b, "From: %s\r\n", s.from)
+    fmt.Fprintf(&b, "To: %s\r\n", message.To)
+    fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\n")
+    fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+    for _, part := range []struct{ contentType, body string }{
+        {"text/plain", message.TextBody},
+        {"text/html", message.HTMLBody},
+    } {
+        fmt.Fprintf(&b, "--%s\r\n", boundary)
+        fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+        b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+        qp := quotedprintable.NewWriter(&b)
+        if _, err := qp.Write([]byte(part.body)); err != nil {
+            return nil, err
+        }
+        qp.Close()
+        b.WriteString("\r\n")
+    }
+    fmt.Fprintf(&b, "--%s--\r\n", boundary)
+    return b.Bytes(), nil
+}
+
+func randomBoundary() (string, error) {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,181 @@
+package smtp
+
+import (
+    "bufio"
+    "io"
+    "mime"
+    "mime/multipart"
+    "mime/quotedprintable"
+    "net"
+    "net/mail"
+    "strings"
+    "testing"
+
+    "telegramassist/internal/domain"
+)
+
+// fakeServer accepts one SMTP conversation without offering STARTTLS or
+// AUTH and records the commands and the message data it received.
+type fakeServer struct {
+    addr     string
+    commands []string
+    data     string
+    done     chan struct{}
+}
+
+func startFakeServer(t *testing.T) *fakeServer {
+    t.Helper()
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { ln.Close() })
+
+    server := &fakeServer{addr: ln.Addr().String(), done: make(chan struct{})}
+    go func() {
+        defer close(server.done)
+        conn, err := ln.Accept()
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        server.serve(conn)
+    }()
+    return server
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+    r := bufio.NewReader(conn)
+    reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+    reply("220 localhost ESMTP fake")
+    for {
+        line, err := r.ReadString('\n')
+        if err != nil {
+            return
+        }
+        line = strings.TrimRight(line, "\r\n")
+        s.commands = append(s.commands, line)
+
+        switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+        case "EHLO":
+            reply("250-localhost")
+            reply("250 8BITMIME")
+        case "MAIL", "RCPT":
+            reply("250 OK")
+        case "DATA":
+            reply("354 End data with <CR><LF>.<CR><LF>")
+            var data strings.Builder
+            for {
+                line, err := r.ReadString('\n')
+                if err != nil {
+                    return
+                }
+                if line == ".\r\n" {
+                    break
+                }
+                data.WriteString(strings.TrimPrefix(line, "."))
+            }
+            s.data = data.String()
+            reply("250 OK")
+        case "QUIT":
+            reply("221 Bye")
+            return
+        default:
+            reply("502 Command not implemented")
+        }
+    }
+}
+
+func TestSendEmailWithoutStartTLS(t *testing.T) {
+    server := startFakeServer(t)
+    host, port, _ := net.SplitHostPort(server.addr)
+    sender := &Sender{host: host, port: port, from: "alertas@example.com"}
+
+    err := sender.SendEmail(domain.EmailMessage{
+        To:       "ana@example.com",
+        Subject:  "🚨 Sensor KY_026 activado",
+        TextBody: "El sensor se ha activado.",
+        HTMLBody: "<p>El sensor se ha <strong>activado</strong>.</p>",
+    })
+    if err != nil {
+        t.Fatalf("SendEmail() error = %v", err)
+    }
+    <-server.done
+
+    for _, command := range server.commands {
+        if strings.HasPrefix(command, "STARTTLS") || strings.HasPrefix(command, "AUTH") {
+            t.Errorf("unexpected command %q", command)
+        }
+    }
+    wantCommands := []string{"MAIL FROM:<alertas@example.com>", "RCPT TO:<ana@example.com>", "DATA", "QUIT"}
+    for _, want := range wantCommands {
+        found := false
+        for _, command := range server.commands {
+            found = found || strings.HasPrefix(command, want)
+        }
+        if !found {
+            t.Errorf("command %q not sent, got %q", want, server.commands)
+        }
+    }
+
+    msg, err := mail.ReadMessage(strings.NewReader(server.data))
+    if err != nil {
+        t.Fatalf("invalid message: %v", err)
+    }
+    if got := msg.Header.Get("To"); got != "ana@example.com" {
+        t.Errorf("To = %q", got)
+    }
+    subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+    if err != nil || subject != "🚨 Sensor KY_026 activado" {
+        t.Errorf("Subject = %q (%v)", subject, err)
+    }
+
+    mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+    if err != nil || mediaType != "multipart/alternative" {
+        t.Fatalf("Content-Type = %q (%v)", msg.Header.Get("Content-Type"), err)
+    }
+    parts := multipart.NewReader(msg.Body, params["boundary"])
+    wantParts := []struct{ contentType, body string }{
+        {"text/plain", "El sensor se ha activado."},
+        {"text/html", "<p>El sensor se ha <strong>activado</strong>.</p>"},
+    }
+    for _, want := range wantParts {
+        part, err := parts.NextRawPart()
+        if err != nil {
+            t.Fatalf("missing %s part: %v", want.contentType, err)
+        }
+        if got, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); got != want.contentType {
+            t.Errorf("part Content-Type = %q, want %q", got, want.contentType)
+        }
+        body, err := io.ReadAll(quotedprintable.NewReader(part))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := strings.TrimRight(string(body), "\r\n"); got != want.body {
+            t.Errorf("%s body = %q, want %q", want.contentType, got, want.body)
+        }
+    }
+    if _, err := parts.NextPart(); err != io.EOF {
+        t.Errorf("expected two parts, got more (%v)", err)
+    }
+}
+
+func TestComposeRejectsHeaderInjection(t *testing.T) {
+    sender := &Sender{from: "alertas@example.com"}
+
+    for _, to := range []string{
+        "ana@example.com\r\nBcc: eve@example.com",
+        "ana@example.com\nBcc: eve@example.com",
+        "ana@example.com\r",
+    } {
+        if _, err := sender.compose(domain.EmailMessage{To: to}); err == nil {
+            t.Errorf("compose(%q) succeeded, want an error", to)
+        }
+    }
+
+    if _, err := sender.compose(domain.EmailMessage{To: "ana@example.com"}); err != nil {
+        t.Errorf("compose() error = %v", err)
+    }
+}
@@ -3,11 +3,29 @@ package server
 import (
     "log"
     "net/http"
+    "os"
     "telegramassist/internal/api"
 )
 
-func StartHTTPServer(alertHandler *api.AlertHandler) {
+func StartHTTPServer(
+    alertHandler *api.AlertHandler,
+    deviceKeyHandler *api.DeviceKeyHandler,
+    webhookHandler *api.WebhookHandler,
+    preferencesHandler *api.PreferencesHandler,
+    readingsHandler *api.ReadingsHandler,
+    streamHandler *api.StreamHandler,
+    healthHandler *api.HealthHandler,
+) {
     http.HandleFunc("/api/alerts", alertHandler.HandleAlert)
+    http.HandleFunc("/api/alerts/batch", alertHandler.HandleAlertBatch)
+    http.HandleFunc("/api/alerts/", api.RequireToken(os.Getenv("API_READ_TOKEN"), alertHandler.HandleAlertStatus))
+    http.HandleFunc("/api/admin/devices/", api.RequireToken(os.Getenv("ADMIN_API_TOKEN"), deviceKeyHandler.HandleDeviceKeys))
+    http.HandleFunc("/api/admin/webhooks", api.RequireToken(os.Getenv("ADMIN_API_TOKEN"), webhookHandler.HandleWebhooks))
+    http.HandleFunc("/api/admin/webhooks/", api.RequireToken(os.Getenv("ADMIN_API_TOKEN"), webhookHandler.HandleWebhooks))
+    http.HandleFunc("/api/admin/users/", api.RequireToken(os.Getenv("ADMIN_API_TOKEN"), preferencesHandler.HandlePreferences))
+    http.HandleFunc("/api/devices/", api.RequireToken(os.Getenv("API_READ_TOKEN"), readingsHandler.HandleDeviceReadings))
+    http.HandleFunc("/health", healthHandler.HandleHealth)
+    http.HandleFunc("/api/stream", api.RequireStreamToken(os.Getenv("API_READ_TOKEN"), streamHandler.HandleStream))
 
     go func() {
  	   log.Println("Iniciando servidor HTTP en :8080...")
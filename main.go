@@ -1,12 +1,26 @@
 package main
 
 import (
+    "context"
+    "errors"
+    "fmt"
     "log"
+    "os"
+    "os/signal"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
     "telegramassist/internal/api"
     "telegramassist/internal/application"
+    "telegramassist/internal/infrastructure/mqtt"
     "telegramassist/internal/infrastructure/mysql"
     "telegramassist/internal/infrastructure/rabbitmq"
+    "telegramassist/internal/infrastructure/sms"
+    "telegramassist/internal/infrastructure/smtp"
     "telegramassist/internal/bot"
+    "telegramassist/internal/domain"
+    "telegramassist/internal/domain/ports"
     "telegramassist/internal/server"
     
 
@@ -28,25 +42,234 @@ func main() {
 
     // Initialize Services
     ky026Service := application.NewKY026Service(mysqlRepo)
-    esp32Service := application.NewESP32Service(mysqlRepo, ky026Service)
+    incidentService := application.NewIncidentService(mysqlRepo)
+    esp32Service := application.NewESP32Service(mysqlRepo, ky026Service, incidentService, mysqlRepo)
     
     // Initialize Bot Handler
-    botHandler := bot.NewBotHandler(esp32Service, ky026Service)
-    botHandler.Start()
+    botHandler := bot.NewBotHandler(esp32Service, ky026Service, incidentService)
+    botHandler.Init()
 
-    // Initialize RabbitMQ Service
+    // Initialize RabbitMQ Service and connect in the background
     rabbitMQService := rabbitmq.NewRabbitMQService()
+    rabbitMQService.Start()
+
+    // Publish the notifications stored in the outbox
+    outboxRelay := application.NewOutboxRelay(
+        mysqlRepo,
+        rabbitMQService,
+        envSeconds("OUTBOX_POLL_INTERVAL", 2*time.Second),
+        envInt("OUTBOX_MAX_ATTEMPTS", 20),
+    )
+    outboxRelay.Start()
 
     // Initialize Notification Service with the bot
-    notificationService := application.NewNotificationService(botHandler.Bot)
+    notificationService := application.NewNotificationService(botHandler.Bot, mysqlRepo, application.TelegramQueueConfig{
+        Workers:         envInt("TELEGRAM_SEND_WORKERS", 4),
+        Capacity:        envInt("TELEGRAM_QUEUE_CAPACITY", 1000),
+        GlobalPerSecond: envInt("TELEGRAM_GLOBAL_RATE", 25),
+        ChatPerMinute:   envInt("TELEGRAM_CHAT_RATE", 20),
+    })
+    botHandler.SetNotificationService(notificationService)
+    botHandler.Start()
 
-    // Initialize Alert Handler with correct services
-    alertHandler := api.NewAlertHandler(
+    // Initialize the escalation ladder and reload pending escalations
+    escalationService := application.NewEscalationService(
+        mysqlRepo,
+        incidentService,
         esp32Service,
         notificationService,
-        rabbitMQService,
     )
+    if err := escalationService.Start(); err != nil {
+        log.Fatal(err)
+    }
+
+    // Initialize device authentication for incoming alerts
+    deviceAuthService := application.NewDeviceAuthService(
+        esp32Service,
+        envSeconds("ALERT_SIGNATURE_WINDOW", 5*time.Minute),
+    )
+
+    // Initialize the hub that streams processed alerts
+    alertHub := application.NewAlertHub(envInt("ALERT_STREAM_BUFFER", 500))
+
+    // Initialize the webhook channel and send pending deliveries
+    webhookNotifier := application.NewWebhookNotifier(
+        mysqlRepo,
+        envInt("WEBHOOK_MAX_ATTEMPTS", 8),
+        envInt("WEBHOOK_DISABLE_AFTER", 20),
+    )
+    webhookNotifier.Start()
+    webhookHandler := api.NewWebhookHandler(webhookNotifier)
+
+    // Initialize the notification channels; email and SMS only when their
+    // provider is configured
+    channels := []ports.NotificationChannel{notificationService, application.OutboxChannel{}, webhookNotifier}
+    if emailSender := smtp.NewSender(); emailSender.Enabled() {
+        channels = append(channels, application.NewEmailNotifier(emailSender))
+    }
+    if smsSender := sms.NewHTTPSender(); smsSender.Enabled() {
+        channels = append(channels, application.NewSMSNotifier(smsSender))
+    }
+    notificationDispatcher := application.NewNotificationDispatcher(esp32Service, mysqlRepo, channels...)
+    preferencesHandler := api.NewPreferencesHandler(application.NewPreferencesService(mysqlRepo, mysqlRepo))
+
+    // Collapse the alerts of sensors that toggle repeatedly
+    flapDetector := application.NewFlapDetector(flapConfig())
+
+    // Initialize the pipeline shared by every alert transport
+    alertProcessor := application.NewAlertProcessor(
+        esp32Service,
+        notificationDispatcher,
+        escalationService,
+        alertHub,
+        flapDetector,
+    )
+
+    // Process the accepted alerts in the background, reloading the queued ones
+    alertQueue := application.NewAlertQueue(
+        mysqlRepo,
+        alertProcessor,
+        envInt("ALERT_WORKERS", 4),
+        envSeconds("ALERT_RETRY_INTERVAL", 30*time.Second),
+    )
+    // Replay the suppressed alerts through the queue once they settle
+    flapDetector.SetSettleHandler(alertQueue.Settle)
+    alertQueue.Start()
+
+    // Gateways upload batches only for the serials bound to their token in
+    // GATEWAY_TOKENS, e.g. "token1=ESP32-001,ESP32-002;token2=ESP32-003"
+    gatewayTokens, err := api.ParseGatewayTokens(os.Getenv("GATEWAY_TOKENS"))
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    // Initialize Alert Handler with correct services
+    alertHandler := api.NewAlertHandler(alertQueue, deviceAuthService, gatewayTokens)
+
+    // Initialize the admin API for device credentials
+    deviceKeyService := application.NewDeviceKeyService(
+        esp32Service,
+        mysqlRepo,
+        envSeconds("DEVICE_KEY_OVERLAP", 24*time.Hour),
+    )
+    deviceKeyHandler := api.NewDeviceKeyHandler(deviceKeyService)
+
+    // Initialize the read API used by the dashboard
+    readingsHandler := api.NewReadingsHandler(esp32Service, ky026Service)
+
+    streamHandler := api.NewStreamHandler(alertHub)
+
+    // Receive alerts published by the devices over MQTT, when a broker is configured
+    alertSubscriber := mqtt.NewAlertSubscriber(func(alert *domain.Alert) error {
+        _, _, err := alertQueue.Submit(alert)
+        if errors.Is(err, domain.ErrInvalidAlert) || errors.Is(err, application.ErrUnknownDevice) {
+            return fmt.Errorf("%w: %v", mqtt.ErrPoisonMessage, err)
+        }
+        return err
+    })
+
+    healthChecks := map[string]api.HealthCheck{
+        "rabbitmq": rabbitMQService,
+        "telegram": notificationService,
+    }
+    if alertSubscriber.Enabled() {
+        alertSubscriber.Start()
+        healthChecks["mqtt"] = alertSubscriber
+    }
+    healthHandler := api.NewHealthHandler(healthChecks)
 
     // Initialize and start the HTTP server
-    server.StartHTTPServer(alertHandler)
-}
\ No newline at end of file
+    server.StartHTTPServer(alertHandler, deviceKeyHandler, webhookHandler, preferencesHandler, readingsHandler, streamHandler, healthHandler)
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Consume alerts published by gateways, when an input queue is configured
+    alertConsumer := rabbitmq.NewAlertConsumer(func(alert *domain.Alert) error {
+        _, _, err := alertQueue.Submit(alert)
+        if errors.Is(err, domain.ErrInvalidAlert) || errors.Is(err, application.ErrUnknownDevice) {
+            return fmt.Errorf("%w: %v", rabbitmq.ErrPoisonMessage, err)
+        }
+        return err
+    })
+    if alertConsumer.Enabled() {
+        alertConsumer.Start(ctx)
+    }
+
+    // The bot and the HTTP server run in background goroutines
+    <-ctx.Done()
+    log.Println("Apagando...")
+
+    if alertConsumer.Enabled() {
+        alertConsumer.Wait()
+    }
+    if alertSubscriber.Enabled() {
+        alertSubscriber.Stop()
+    }
+    alertQueue.Stop()
+    flapDetector.Stop()
+    escalationService.Stop()
+    webhookNotifier.Stop()
+    notificationService.Stop()
+    outboxRelay.Stop()
+    rabbitMQService.Close()
+}
+
+// flapConfig reads the default debounce policy from FLAP_DEBOUNCE,
+// FLAP_WINDOW (seconds) and FLAP_THRESHOLD, and the policy of each sensor
+// type listed in FLAP_SENSORS from the same variables suffixed with the
+// sensor, e.g. FLAP_WINDOW_KY_026. Zero disables debouncing or flapping
+// detection.
+func flapConfig() application.FlapConfig {
+    config := application.FlapConfig{
+        Default: domain.FlapPolicy{
+            Debounce:  envDuration("FLAP_DEBOUNCE", 10*time.Second),
+            Window:    envDuration("FLAP_WINDOW", 2*time.Minute),
+            Threshold: envCount("FLAP_THRESHOLD", 6),
+        },
+        Sensors: make(map[string]domain.FlapPolicy),
+    }
+    for _, sensor := range strings.Split(os.Getenv("FLAP_SENSORS"), ",") {
+        if sensor = strings.TrimSpace(sensor); sensor == "" {
+            continue
+        }
+        config.Sensors[sensor] = domain.FlapPolicy{
+            Debounce:  envDuration("FLAP_DEBOUNCE_"+sensor, config.Default.Debounce),
+            Window:    envDuration("FLAP_WINDOW_"+sensor, config.Default.Window),
+            Threshold: envCount("FLAP_THRESHOLD_"+sensor, config.Default.Threshold),
+        }
+    }
+    return config
+}
+
+// envDuration is envCount for a duration expressed in seconds.
+func envDuration(key string, fallback time.Duration) time.Duration {
+    return time.Duration(envCount(key, int(fallback/time.Second))) * time.Second
+}
+
+// envCount reads a non-negative integer from the environment.
+func envCount(key string, fallback int) int {
+    value, err := strconv.Atoi(os.Getenv(key))
+    if err != nil || value < 0 {
+        return fallback
+    }
+    return value
+}
+
+// envInt reads a positive integer from the environment.
+func envInt(key string, fallback int) int {
+    value, err := strconv.Atoi(os.Getenv(key))
+    if err != nil || value <= 0 {
+        return fallback
+    }
+    return value
+}
+
+// envSeconds reads a duration expressed in seconds from the environment.
+func envSeconds(key string, fallback time.Duration) time.Duration {
+    seconds, err := strconv.Atoi(os.Getenv(key))
+    if err != nil || seconds <= 0 {
+        return fallback
+    }
+    return time.Duration(seconds) * time.Second
+}
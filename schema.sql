@@ -22,3 +22,197 @@ CREATE TABLE IF NOT EXISTS ky_026 (
     estado VARCHAR(50) NOT NULL,
     FOREIGN KEY (esp32_serial) REFERENCES esp32m(serial)
 );
+
+-- Credenciales por dispositivo. Durante una rotación la clave anterior
+-- sigue siendo válida hasta expires_at.
+CREATE TABLE IF NOT EXISTS esp32_credentials (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    esp32_serial VARCHAR(50) NOT NULL,
+    key_id VARCHAR(32) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    UNIQUE KEY unique_esp32_key (esp32_serial, key_id),
+    INDEX idx_credentials_serial (esp32_serial)
+);
+
+-- Incidentes: agrupan la activación y desactivación de un sensor
+-- (open -> acknowledged -> resolved / false_alarm)
+CREATE TABLE IF NOT EXISTS incidents (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    esp32_serial VARCHAR(50) NOT NULL,
+    sensor VARCHAR(45) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    opened_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    closed_at DATETIME NULL,
+    -- el sensor cambia de estado repetidamente: sus alertas se agrupan en
+    -- este incidente
+    flapping BOOLEAN NOT NULL DEFAULT FALSE,
+    INDEX idx_incidents_open (esp32_serial, sensor, closed_at)
+);
+
+-- Historial de cambios de estado de cada incidente (quién y cuándo)
+CREATE TABLE IF NOT EXISTS incident_transitions (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    incident_id INT NOT NULL,
+    from_status VARCHAR(20) NULL,
+    to_status VARCHAR(20) NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (incident_id) REFERENCES incidents(id)
+);
+
+-- Mensajes de Telegram enviados por incidente, para poder editarlos
+CREATE TABLE IF NOT EXISTS incident_messages (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    incident_id INT NOT NULL,
+    chat_id BIGINT NOT NULL,
+    message_id INT NOT NULL,
+    text TEXT NOT NULL,
+    FOREIGN KEY (incident_id) REFERENCES incidents(id)
+);
+
+-- Contacto de emergencia que se ofrece en los botones de la alerta. MySQL no
+-- tiene ADD COLUMN IF NOT EXISTS: la columna se agrega solo si falta, para
+-- que el archivo se pueda ejecutar más de una vez
+SET @ddl = (SELECT IF(COUNT(*) = 0,
+    'ALTER TABLE ESP32 ADD COLUMN emergency_contact VARCHAR(50) NULL', 'DO 0')
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'ESP32' AND COLUMN_NAME = 'emergency_contact');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+-- Política de escalamiento por dispositivo (minutos desde que se abre el
+-- incidente; NULL desactiva el paso)
+CREATE TABLE IF NOT EXISTS escalation_policies (
+    esp32_serial VARCHAR(50) PRIMARY KEY,
+    renotify_minutes INT NULL,
+    secondary_minutes INT NULL,
+    webhook_minutes INT NULL,
+    webhook_url VARCHAR(255) NULL
+);
+
+-- Contactos secundarios (chats de Telegram) a los que se escala
+CREATE TABLE IF NOT EXISTS escalation_contacts (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    esp32_serial VARCHAR(50) NOT NULL,
+    chat_id BIGINT NOT NULL,
+    UNIQUE KEY unique_escalation_contact (esp32_serial, chat_id)
+);
+
+-- Pasos de escalamiento programados; los pendientes se recargan al iniciar
+CREATE TABLE IF NOT EXISTS incident_escalations (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    incident_id INT NOT NULL,
+    step INT NOT NULL,
+    due_at DATETIME NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    executed_at DATETIME NULL,
+    INDEX idx_escalations_status (status, due_at),
+    FOREIGN KEY (incident_id) REFERENCES incidents(id)
+);
+
+-- Notificaciones pendientes de publicar en RabbitMQ (transactional outbox);
+-- se escriben en la misma transacción que la lectura. status: pending, sent
+-- o failed (tras OUTBOX_MAX_ATTEMPTS intentos)
+CREATE TABLE IF NOT EXISTS notification_outbox (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    routing_key VARCHAR(255) NOT NULL, -- alert.<sensor>.<severity>.<serial>
+    payload JSON NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error TEXT NULL,
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME NULL,
+    INDEX idx_outbox_pending (status, next_attempt_at)
+);
+
+-- Endpoints HTTPS suscritos a las alertas de un dispositivo o de un usuario
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    esp32_serial VARCHAR(50) NULL,
+    user_id INT NULL,
+    url VARCHAR(500) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    format VARCHAR(20) NOT NULL DEFAULT 'json',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    disabled_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_webhooks_serial (esp32_serial),
+    INDEX idx_webhooks_user (user_id)
+);
+
+-- Registro de entregas de webhooks; las pendientes se reintentan con backoff
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    subscription_id INT NOT NULL,
+    payload JSON NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_status_code INT NULL,
+    last_error TEXT NULL,
+    next_attempt_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    delivered_at DATETIME NULL,
+    INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id)
+);
+
+-- Canales de notificación de cada usuario; sin fila se usa solo Telegram.
+-- enable_device_chats se aplica a todos los chats vinculados a sus dispositivos
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INT PRIMARY KEY,
+    enable_device_chats BOOLEAN NOT NULL DEFAULT TRUE,
+    enable_email BOOLEAN NOT NULL DEFAULT FALSE,
+    enable_sms BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at DATETIME NOT NULL
+);
+
+-- Teléfono (E.164) para las notificaciones por SMS; se agrega solo si falta
+SET @ddl = (SELECT IF(COUNT(*) = 0,
+    'ALTER TABLE users ADD COLUMN phone VARCHAR(20) NULL', 'DO 0')
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'phone');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+-- Alertas aceptadas por la API y procesadas en segundo plano; las que siguen
+-- en "queued" o "processing" se recargan al iniciar, y las "flapping_pending"
+-- (suprimidas por un sensor que cambia de estado repetidamente) se repiten en
+-- settle_at. dedup_key hace que los reintentos de un dispositivo se guarden
+-- una sola vez
+CREATE TABLE IF NOT EXISTS alerts (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    event_id VARCHAR(64) NULL,
+    dedup_key CHAR(64) NULL,
+    numero_serie VARCHAR(50) NOT NULL,
+    sensor VARCHAR(50) NOT NULL,
+    estado INT NOT NULL,
+    fecha_activacion VARCHAR(45) NOT NULL,
+    fecha_desactivacion VARCHAR(30) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    incident_id INT NULL,
+    delivery JSON NULL,
+    last_error TEXT NULL,
+    received_at DATETIME NOT NULL,
+    processed_at DATETIME NULL,
+    settle_at DATETIME NULL,
+    -- alertas recibidas por lotes: su lectura se guarda junto con ellas
+    reading_stored BOOLEAN NOT NULL DEFAULT FALSE,
+    -- mensajes RabbitMQ escritos en el outbox junto con la alerta
+    outbox_queued INT NOT NULL DEFAULT 0,
+    -- la notificación de la alerta no se ha enviado aún: se reenvía si el
+    -- proceso se detuvo tras guardar el checkpoint
+    notify_pending BOOLEAN NOT NULL DEFAULT FALSE,
+    incident_opened BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE KEY uq_alerts_dedup_key (dedup_key),
+    INDEX idx_alerts_status (status, id),
+    INDEX idx_alerts_sensor (numero_serie, sensor, status)
+);